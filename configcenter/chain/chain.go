@@ -0,0 +1,200 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/lerity-yao/czt-contrib/configcenter/consul"
+	"github.com/lerity-yao/czt-contrib/configcenter/nacos"
+	"github.com/zeromicro/go-zero/core/configcenter/subscriber"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type (
+	// Subscriber is the interface shared by the config center backends.
+	// It extends the go-zero subscriber.Subscriber, so every backend,
+	// as well as a chain of them, works with configurator.MustNewConfigCenter.
+	Subscriber interface {
+		subscriber.Subscriber
+		// Stop releases the resources of the subscriber, e.g. its watch goroutine.
+		Stop()
+	}
+
+	// ChainSubscriber reads several backends in ascending precedence and
+	// deep-merges their values into one JSON value, later backends win.
+	ChainSubscriber struct {
+		sources   []source
+		listeners []func()
+		lock      sync.Mutex
+		last      string
+	}
+
+	source struct {
+		name     string
+		sub      Subscriber
+		optional bool
+	}
+)
+
+var (
+	_ Subscriber = (*consul.ConsulSubscriber)(nil)
+	_ Subscriber = (*nacos.NacosSubscriber)(nil)
+	_ Subscriber = (*FileSubscriber)(nil)
+	_ Subscriber = (*EnvSubscriber)(nil)
+	_ Subscriber = (*ChainSubscriber)(nil)
+)
+
+// MustNewChainSubscriber returns a ChainSubscriber, exits on errors.
+func MustNewChainSubscriber(conf Conf) *ChainSubscriber {
+	s, err := NewChainSubscriber(conf)
+	logx.Must(err)
+	return s
+}
+
+// NewChainSubscriber returns a ChainSubscriber of the configured sources.
+func NewChainSubscriber(conf Conf) (*ChainSubscriber, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	sources := make([]source, 0, len(conf.Sources))
+	for i, c := range conf.Sources {
+		sub, err := newSubscriber(c)
+		if err != nil {
+			for _, s := range sources {
+				s.sub.Stop()
+			}
+			return nil, fmt.Errorf("config center source %d: %w", i, err)
+		}
+
+		sources = append(sources, source{
+			name:     fmt.Sprintf("%d:%s", i, c.Type),
+			sub:      sub,
+			optional: c.Optional,
+		})
+	}
+
+	return newChain(sources), nil
+}
+
+// NewChain returns a ChainSubscriber of subscribers in ascending precedence, none of them optional.
+func NewChain(subscribers ...Subscriber) *ChainSubscriber {
+	sources := make([]source, 0, len(subscribers))
+	for i, sub := range subscribers {
+		sources = append(sources, source{
+			name: fmt.Sprintf("%d:%T", i, sub),
+			sub:  sub,
+		})
+	}
+
+	return newChain(sources)
+}
+
+func newChain(sources []source) *ChainSubscriber {
+	c := &ChainSubscriber{
+		sources: sources,
+	}
+	for _, s := range sources {
+		// the subscribers in this repo never fail to add a listener
+		_ = s.sub.AddListener(c.onChange)
+	}
+
+	return c
+}
+
+func newSubscriber(c SourceConf) (Subscriber, error) {
+	switch c.Type {
+	case SourceFile:
+		return NewFileSubscriber(c.File)
+	case SourceConsul:
+		return consul.NewConsulSubscriber(c.Consul)
+	case SourceNacos:
+		return nacos.NewNacosSubscriber(c.Nacos)
+	case SourceEnv:
+		return NewEnvSubscriber(c.Env)
+	default:
+		return nil, fmt.Errorf("unknown source type: %s", c.Type)
+	}
+}
+
+// AddListener adds a listener, called when the merged value changes.
+func (c *ChainSubscriber) AddListener(listener func()) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.listeners = append(c.listeners, listener)
+	return nil
+}
+
+// Value returns the deep-merged value of all sources as a JSON string.
+// Keys are lower-cased, so that sources with different key cases merge,
+// go-zero matches them case-insensitively when loading the value. A string
+// overriding a number or a bool, e.g. from env, is converted to that type.
+func (c *ChainSubscriber) Value() (string, error) {
+	var merged map[string]any
+	for _, s := range c.sources {
+		val, err := s.sub.Value()
+		if err != nil {
+			if s.optional {
+				logx.Errorf("read config center source %s failed, skipped: %v", s.name, err)
+				continue
+			}
+			return "", fmt.Errorf("read config center source %s: %w", s.name, err)
+		}
+		if len(val) == 0 {
+			continue
+		}
+
+		var settings map[string]any
+		if err = json.Unmarshal([]byte(val), &settings); err != nil {
+			return "", fmt.Errorf("parse config center source %s: %w", s.name, err)
+		}
+		merged = consul.MergeSettings(merged, coerceScalars(merged, lowerKeys(settings)))
+	}
+
+	if merged == nil {
+		c.remember("")
+		return "", nil
+	}
+
+	marshal, _ := json.Marshal(merged)
+	c.remember(string(marshal))
+	return string(marshal), nil
+}
+
+// Stop stops all sources.
+func (c *ChainSubscriber) Stop() {
+	for _, s := range c.sources {
+		s.sub.Stop()
+	}
+}
+
+// onChange notifies the listeners if a change of a source changed the merged value,
+// e.g. a change overridden by a source of higher precedence is not propagated.
+func (c *ChainSubscriber) onChange() {
+	c.lock.Lock()
+	last := c.last
+	c.lock.Unlock()
+
+	val, err := c.Value()
+	if err != nil {
+		logx.Errorf("read config center chain failed: %v", err)
+		return
+	}
+	if val == last {
+		return
+	}
+
+	c.lock.Lock()
+	listeners := append([]func(){}, c.listeners...)
+	c.lock.Unlock()
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+func (c *ChainSubscriber) remember(val string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.last = val
+}
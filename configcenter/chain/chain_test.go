@@ -0,0 +1,350 @@
+package chain
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zeromicro/go-zero/core/conf"
+)
+
+type mockSubscriber struct {
+	mu        sync.Mutex
+	val       string
+	err       error
+	listeners []func()
+	stopped   bool
+}
+
+func (m *mockSubscriber) AddListener(listener func()) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, listener)
+	return nil
+}
+
+func (m *mockSubscriber) Value() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.val, m.err
+}
+
+func (m *mockSubscriber) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopped = true
+}
+
+func (m *mockSubscriber) set(val string) {
+	m.mu.Lock()
+	m.val = val
+	listeners := append([]func(){}, m.listeners...)
+	m.mu.Unlock()
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+func decode(t *testing.T, val string) map[string]any {
+	t.Helper()
+	var settings map[string]any
+	if err := json.Unmarshal([]byte(val), &settings); err != nil {
+		t.Fatalf("invalid JSON %q: %v", val, err)
+	}
+	return settings
+}
+
+func TestConf_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    Conf
+		wantErr bool
+	}{
+		{"empty", Conf{}, true},
+		{"file", Conf{Sources: []SourceConf{{Type: SourceFile, File: FileConf{Path: "a.yaml"}}}}, false},
+		{"file without path", Conf{Sources: []SourceConf{{Type: SourceFile}}}, true},
+		{"env", Conf{Sources: []SourceConf{{Type: SourceEnv, Env: EnvConf{Prefix: "APP_"}}}}, false},
+		{"env without prefix", Conf{Sources: []SourceConf{{Type: SourceEnv}}}, true},
+		{"consul", Conf{Sources: []SourceConf{{Type: SourceConsul}}}, false},
+		{"unknown", Conf{Sources: []SourceConf{{Type: "etcd"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.conf.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCoerceScalars(t *testing.T) {
+	dst := map[string]any{
+		"debug": false,
+		"redis": map[string]any{"port": 6379.0, "pass": "secret"},
+		"rate":  1.5,
+	}
+	src := map[string]any{
+		"debug": "true",
+		"redis": map[string]any{"port": "6380", "pass": "123456"},
+		"rate":  "fast",
+		"id":    "42",
+	}
+
+	got := coerceScalars(dst, src)
+	want := map[string]any{
+		"debug": true,
+		"redis": map[string]any{"port": 6380.0, "pass": "123456"},
+		"rate":  "fast",
+		"id":    "42",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("coerceScalars() = %v, want %v", got, want)
+	}
+}
+
+func TestChain_Value(t *testing.T) {
+	low := &mockSubscriber{val: `{"Name":"a","Redis":{"Host":"localhost","Port":6379}}`}
+	high := &mockSubscriber{val: `{"redis":{"host":"redis"}}`}
+	c := NewChain(low, high)
+
+	val, err := c.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+
+	want := map[string]any{
+		"name":  "a",
+		"redis": map[string]any{"host": "redis", "port": 6379.0},
+	}
+	if got := decode(t, val); !reflect.DeepEqual(got, want) {
+		t.Errorf("Value() = %v, want %v", got, want)
+	}
+}
+
+func TestChain_ValueEmpty(t *testing.T) {
+	c := NewChain(&mockSubscriber{}, &mockSubscriber{})
+
+	val, err := c.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if val != "" {
+		t.Errorf("Value() = %q, want empty", val)
+	}
+}
+
+func TestChain_ValueError(t *testing.T) {
+	c := NewChain(&mockSubscriber{val: `{"a":1}`}, &mockSubscriber{err: errors.New("down")})
+
+	if _, err := c.Value(); err == nil {
+		t.Error("expected error of the failed source")
+	}
+}
+
+func TestChain_ValueInvalidJSON(t *testing.T) {
+	c := NewChain(&mockSubscriber{val: "not json"})
+
+	if _, err := c.Value(); err == nil {
+		t.Error("expected error of the invalid source value")
+	}
+}
+
+func TestChain_OptionalSource(t *testing.T) {
+	c := newChain([]source{
+		{name: "0:file", sub: &mockSubscriber{err: errors.New("missing")}, optional: true},
+		{name: "1:mock", sub: &mockSubscriber{val: `{"a":1}`}},
+	})
+
+	val, err := c.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if got := decode(t, val); !reflect.DeepEqual(got, map[string]any{"a": 1.0}) {
+		t.Errorf("Value() = %v", got)
+	}
+}
+
+func TestChain_Listener(t *testing.T) {
+	low := &mockSubscriber{val: `{"a":1,"b":1}`}
+	high := &mockSubscriber{val: `{"b":2}`}
+	c := NewChain(low, high)
+
+	var called int32
+	if err := c.AddListener(func() {
+		atomic.AddInt32(&called, 1)
+	}); err != nil {
+		t.Fatalf("AddListener() error: %v", err)
+	}
+	if _, err := c.Value(); err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+
+	// overridden by the source of higher precedence, the merged value is unchanged
+	low.set(`{"a":1,"b":3}`)
+	if n := atomic.LoadInt32(&called); n != 0 {
+		t.Fatalf("listener called %d times, want 0", n)
+	}
+
+	low.set(`{"a":2,"b":1}`)
+	if n := atomic.LoadInt32(&called); n != 1 {
+		t.Fatalf("listener called %d times, want 1", n)
+	}
+
+	high.set(`{"b":4}`)
+	if n := atomic.LoadInt32(&called); n != 2 {
+		t.Fatalf("listener called %d times, want 2", n)
+	}
+}
+
+func TestChain_Stop(t *testing.T) {
+	a, b := &mockSubscriber{}, &mockSubscriber{}
+	NewChain(a, b).Stop()
+
+	if !a.stopped || !b.stopped {
+		t.Error("expected all sources stopped")
+	}
+}
+
+func TestNewChainSubscriber(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	content := "Name: app\nDebug: false\nRedis:\n  Host: localhost\n  Port: 6379\n  Pass: secret\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CHAIN_TEST_DEBUG", "true")
+	t.Setenv("CHAIN_TEST_REDIS__HOST", "redis")
+	t.Setenv("CHAIN_TEST_REDIS__PORT", "6380")
+	t.Setenv("CHAIN_TEST_REDIS__PASS", "123456")
+
+	c, err := NewChainSubscriber(Conf{Sources: []SourceConf{
+		{Type: SourceFile, File: FileConf{Path: path}},
+		{Type: SourceFile, File: FileConf{Path: filepath.Join(t.TempDir(), "missing.json")}, Optional: true},
+		{Type: SourceEnv, Env: EnvConf{Prefix: "CHAIN_TEST_", Separator: "__"}},
+	}})
+	if err != nil {
+		t.Fatalf("NewChainSubscriber() error: %v", err)
+	}
+	defer c.Stop()
+
+	val, err := c.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+
+	want := map[string]any{
+		"name":  "app",
+		"debug": true,
+		"redis": map[string]any{"host": "redis", "port": 6380.0, "pass": "123456"},
+	}
+	if got := decode(t, val); !reflect.DeepEqual(got, want) {
+		t.Errorf("Value() = %v, want %v", got, want)
+	}
+
+	var cfg struct {
+		Name  string
+		Debug bool
+		Redis struct {
+			Host string
+			Port int
+			Pass string
+		}
+	}
+	if err = conf.LoadFromJsonBytes([]byte(val), &cfg); err != nil {
+		t.Fatalf("LoadFromJsonBytes() error: %v", err)
+	}
+	if cfg.Redis.Port != 6380 || cfg.Redis.Pass != "123456" || !cfg.Debug {
+		t.Errorf("loaded config = %+v", cfg)
+	}
+}
+
+func TestNewChainSubscriber_InvalidConf(t *testing.T) {
+	if _, err := NewChainSubscriber(Conf{}); err == nil {
+		t.Error("expected error for empty sources")
+	}
+
+	_, err := NewChainSubscriber(Conf{Sources: []SourceConf{
+		{Type: SourceFile, File: FileConf{Path: "app.ini"}},
+	}})
+	if err == nil {
+		t.Error("expected error for unsupported file type")
+	}
+}
+
+func TestFileSubscriber_Types(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app.yaml": "Name: app\n",
+		"app.json": `{"Name":"app"}`,
+		"app.toml": "Name = \"app\"\n",
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := NewFileSubscriber(FileConf{Path: path})
+			if err != nil {
+				t.Fatalf("NewFileSubscriber() error: %v", err)
+			}
+			val, err := s.Value()
+			if err != nil {
+				t.Fatalf("Value() error: %v", err)
+			}
+			if got := decode(t, val); got["name"] != "app" {
+				t.Errorf("Value() = %v", got)
+			}
+		})
+	}
+}
+
+func TestEnvSubscriber_Value(t *testing.T) {
+	t.Setenv("ENV_TEST_NAME", "app")
+	t.Setenv("ENV_TEST_DEBUG", "true")
+	t.Setenv("ENV_TEST_HOSTS", `["a","b"]`)
+	t.Setenv("ENV_TEST_PASS", "123456")
+	t.Setenv("ENV_TEST_VERSION", "1.10")
+	t.Setenv("ENV_TEST_LOG__LEVEL", "error")
+	t.Setenv("ENV_TEST_BAD__", "skipped")
+
+	s, err := NewEnvSubscriber(EnvConf{Prefix: "ENV_TEST_"})
+	if err != nil {
+		t.Fatalf("NewEnvSubscriber() error: %v", err)
+	}
+	val, err := s.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+
+	want := map[string]any{
+		"name":    "app",
+		"debug":   "true",
+		"hosts":   []any{"a", "b"},
+		"pass":    "123456",
+		"version": "1.10",
+		"log":     map[string]any{"level": "error"},
+	}
+	if got := decode(t, val); !reflect.DeepEqual(got, want) {
+		t.Errorf("Value() = %v, want %v", got, want)
+	}
+}
+
+func TestEnvSubscriber_Empty(t *testing.T) {
+	s, _ := NewEnvSubscriber(EnvConf{Prefix: "ENV_TEST_NOTHING_SET_"})
+
+	val, err := s.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if val != "" {
+		t.Errorf("Value() = %q, want empty", val)
+	}
+}
@@ -0,0 +1,81 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lerity-yao/czt-contrib/configcenter/consul"
+	"github.com/lerity-yao/czt-contrib/configcenter/nacos"
+)
+
+const (
+	SourceFile   = "file"
+	SourceConsul = "consul"
+	SourceNacos  = "nacos"
+	SourceEnv    = "env"
+)
+
+type (
+	// Conf lists the sources of a chain in ascending precedence, later sources win.
+	Conf struct {
+		Sources []SourceConf
+	}
+
+	// SourceConf configures one backend of the chain, only the section of Type is used.
+	SourceConf struct {
+		Type   string            `json:",options=file|consul|nacos|env"`
+		File   FileConf          `json:",optional"`
+		Consul consul.ConsulConf `json:",optional"`
+		Nacos  nacos.NacosConf   `json:",optional"`
+		Env    EnvConf           `json:",optional"`
+		// Optional skips the source when it fails to read, instead of failing the chain.
+		Optional bool `json:",optional"`
+	}
+
+	// FileConf configures a local file source.
+	FileConf struct {
+		Path string
+		// Type is the file format, inferred from the extension of Path if empty.
+		Type string `json:",optional,options=yaml|yml|json|toml"`
+	}
+
+	// EnvConf configures an environment variable source, e.g. with the prefix APP_
+	// and the separator __, APP_REDIS__HOST overrides Redis.Host.
+	EnvConf struct {
+		Prefix    string
+		Separator string `json:",default=__"`
+	}
+)
+
+// Validate validates the Conf.
+func (c Conf) Validate() error {
+	if len(c.Sources) == 0 {
+		return errors.New("empty config center sources")
+	}
+
+	for i, source := range c.Sources {
+		if err := source.validate(); err != nil {
+			return fmt.Errorf("config center source %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (c SourceConf) validate() error {
+	switch c.Type {
+	case SourceFile:
+		if len(c.File.Path) == 0 {
+			return errors.New("empty file path")
+		}
+	case SourceEnv:
+		if len(c.Env.Prefix) == 0 {
+			return errors.New("empty env prefix")
+		}
+	case SourceConsul, SourceNacos:
+	default:
+		return fmt.Errorf("unknown source type: %s", c.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package chain
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// EnvSubscriber reads the environment variables with a prefix. The variables
+// are read on every Value call, so its listeners are never called.
+type EnvSubscriber struct {
+	prefix    string
+	separator string
+}
+
+// NewEnvSubscriber returns an EnvSubscriber.
+func NewEnvSubscriber(conf EnvConf) (*EnvSubscriber, error) {
+	separator := conf.Separator
+	if len(separator) == 0 {
+		separator = "__"
+	}
+
+	return &EnvSubscriber{
+		prefix:    conf.Prefix,
+		separator: separator,
+	}, nil
+}
+
+// AddListener adds a listener, it's never called since the environment isn't watched.
+func (s *EnvSubscriber) AddListener(_ func()) error {
+	return nil
+}
+
+// Value returns the matched environment variables as a JSON string. The name after
+// the prefix is split by the separator into lower-cased nested keys. Values are kept
+// as strings, e.g. a numeric password, except JSON objects and arrays such as [1,2].
+// In a chain, a string overriding a number or bool is converted to that type.
+func (s *EnvSubscriber) Value() (string, error) {
+	settings := make(map[string]any)
+	for _, env := range os.Environ() {
+		name, val, ok := strings.Cut(env, "=")
+		if !ok || !strings.HasPrefix(name, s.prefix) {
+			continue
+		}
+
+		path := strings.Split(strings.ToLower(strings.TrimPrefix(name, s.prefix)), s.separator)
+		if !validPath(path) {
+			continue
+		}
+		setPath(settings, path, decodeEnv(val))
+	}
+
+	if len(settings) == 0 {
+		return "", nil
+	}
+
+	marshal, err := json.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+
+	return string(marshal), nil
+}
+
+// Stop does nothing, the environment isn't watched.
+func (s *EnvSubscriber) Stop() {
+}
+
+func decodeEnv(val string) any {
+	trimmed := strings.TrimSpace(val)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return val
+	}
+
+	var decoded any
+	if err := json.Unmarshal([]byte(trimmed), &decoded); err != nil {
+		return val
+	}
+
+	return decoded
+}
+
+func setPath(settings map[string]any, path []string, val any) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := settings[key].(map[string]any)
+		if !ok {
+			child = make(map[string]any)
+			settings[key] = child
+		}
+		settings = child
+	}
+
+	settings[path[len(path)-1]] = val
+}
+
+func validPath(path []string) bool {
+	for _, key := range path {
+		if len(key) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,67 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// FileSubscriber reads a local config file. The file is read on every Value call
+// and isn't watched, so its listeners are never called.
+type FileSubscriber struct {
+	path string
+	typ  string
+}
+
+// NewFileSubscriber returns a FileSubscriber.
+func NewFileSubscriber(conf FileConf) (*FileSubscriber, error) {
+	typ := conf.Type
+	if len(typ) == 0 {
+		typ = strings.ToLower(strings.TrimPrefix(filepath.Ext(conf.Path), "."))
+	}
+	switch typ {
+	case "yaml", "yml", "json", "toml":
+	default:
+		return nil, fmt.Errorf("unsupported config file type: %q", typ)
+	}
+
+	return &FileSubscriber{
+		path: conf.Path,
+		typ:  typ,
+	}, nil
+}
+
+// AddListener adds a listener, it's never called since the file isn't watched.
+func (s *FileSubscriber) AddListener(_ func()) error {
+	return nil
+}
+
+// Value returns the content of the file as a JSON string.
+func (s *FileSubscriber) Value() (string, error) {
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", err
+	}
+
+	v := viper.New()
+	v.SetConfigType(s.typ)
+	if err = v.ReadConfig(bytes.NewBuffer(content)); err != nil {
+		return "", fmt.Errorf("parse config file %s: %w", s.path, err)
+	}
+
+	marshal, err := json.Marshal(v.AllSettings())
+	if err != nil {
+		return "", err
+	}
+
+	return string(marshal), nil
+}
+
+// Stop does nothing, the file isn't watched.
+func (s *FileSubscriber) Stop() {
+}
@@ -0,0 +1,68 @@
+package chain
+
+import (
+	"strconv"
+	"strings"
+)
+
+// lowerKeys returns a copy of settings with all map keys lower-cased.
+func lowerKeys(settings map[string]any) map[string]any {
+	lowered := make(map[string]any, len(settings))
+	for key, val := range settings {
+		lowered[strings.ToLower(key)] = lowerValue(val)
+	}
+
+	return lowered
+}
+
+func lowerValue(val any) any {
+	switch v := val.(type) {
+	case map[string]any:
+		return lowerKeys(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = lowerValue(item)
+		}
+		return out
+	default:
+		return val
+	}
+}
+
+// coerceScalars returns a copy of src with the strings overriding a number or a bool of dst
+// converted to that type, e.g. "6380" from env over 6379 from a file, since go-zero doesn't
+// load a string into a numeric field. Strings over strings or missing keys are kept as is.
+func coerceScalars(dst, src map[string]any) map[string]any {
+	coerced := make(map[string]any, len(src))
+	for key, val := range src {
+		switch v := val.(type) {
+		case map[string]any:
+			if dstMap, ok := dst[key].(map[string]any); ok {
+				coerced[key] = coerceScalars(dstMap, v)
+				continue
+			}
+		case string:
+			coerced[key] = coerceScalar(dst[key], v)
+			continue
+		}
+		coerced[key] = val
+	}
+
+	return coerced
+}
+
+func coerceScalar(base any, val string) any {
+	switch base.(type) {
+	case float64:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+			return f
+		}
+	case bool:
+		if b, err := strconv.ParseBool(strings.TrimSpace(val)); err == nil {
+			return b
+		}
+	}
+
+	return val
+}
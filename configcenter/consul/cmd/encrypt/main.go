@@ -0,0 +1,96 @@
+// Command encrypt produces ENC[AES256_GCM,...] values for Consul KV documents.
+//
+//	encrypt -genkey > secret.key
+//	encrypt -key-file secret.key 'p@ssw0rd'
+//	echo -n 'p@ssw0rd' | CONFIG_SECRET_KEY=... encrypt -key-env CONFIG_SECRET_KEY
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lerity-yao/czt-contrib/configcenter/consul"
+)
+
+var (
+	genKey  = flag.Bool("genkey", false, "print a new base64 AES-256 key and exit")
+	keyFile = flag.String("key-file", "", "the file holding the base64 key")
+	keyEnv  = flag.String("key-env", "", "the env var holding the base64 key")
+	decrypt = flag.Bool("d", false, "decrypt an ENC[AES256_GCM,...] value instead")
+)
+
+func main() {
+	flag.Parse()
+
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "encrypt:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if *genKey {
+		key, err := consul.GenerateSecretKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	}
+
+	key, err := readKey()
+	if err != nil {
+		return err
+	}
+
+	value, err := readValue()
+	if err != nil {
+		return err
+	}
+
+	if *decrypt {
+		plaintext, err := consul.DecryptValue(key, value)
+		if err != nil {
+			return err
+		}
+		fmt.Println(plaintext)
+		return nil
+	}
+
+	encrypted, err := consul.EncryptValue(key, value)
+	if err != nil {
+		return err
+	}
+	fmt.Println(encrypted)
+	return nil
+}
+
+func readKey() ([]byte, error) {
+	switch {
+	case len(*keyFile) > 0:
+		content, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return consul.ParseSecretKey(string(content))
+	case len(*keyEnv) > 0:
+		return consul.ParseSecretKey(os.Getenv(*keyEnv))
+	default:
+		return nil, fmt.Errorf("one of -key-file or -key-env is required")
+	}
+}
+
+// readValue takes the value from the first argument, or from stdin so it stays out of the shell history.
+func readValue() (string, error) {
+	if flag.NArg() > 0 {
+		return flag.Arg(0), nil
+	}
+
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
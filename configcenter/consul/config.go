@@ -1,6 +1,11 @@
 package consul
 
-import "github.com/hashicorp/consul/api"
+import (
+	"errors"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
 
 type Conf struct {
 	Host       string        `json:",optional"`
@@ -10,5 +15,57 @@ type Conf struct {
 	Token      string        `json:",optional"`
 	TLSConfig  api.TLSConfig `json:"TLSConfig,optional"`
 	Key        string        `json:",optional"`
-	Type       string        `json:",default=yaml,options=yaml|hcl|json|xml"`
+	// Keys are additional KV documents deep-merged after Key, later keys win.
+	Keys []string `json:",optional"`
+	// Prefix merges every document under the prefix in lexicographic key order.
+	Prefix string `json:",optional"`
+	Type   string `json:",default=yaml,options=yaml|hcl|json|xml"`
+	// WaitTime is the maximum duration of a blocking query, Consul caps it at 10m.
+	WaitTime time.Duration `json:",default=5m"`
+	// Debounce collapses the KV writes within the interval into one reload, 0 disables it.
+	Debounce time.Duration `json:",default=100ms"`
+	// SnapshotFile keeps the last parsed value, served when Consul is unreachable.
+	SnapshotFile string `json:",optional"`
+	// SecretKeyFile or SecretKeyEnv holds the base64 AES-256 key of the ENC[AES256_GCM,...] values.
+	SecretKeyFile string `json:",optional"`
+	SecretKeyEnv  string `json:",optional"`
+	// TokenFile holds the ACL token and is re-read when it's rotated, it replaces Token.
+	TokenFile string `json:",optional"`
+	// Namespace and Partition are the Consul Enterprise namespace and admin partition of the keys.
+	Namespace string `json:",optional"`
+	Partition string `json:",optional"`
+}
+
+// Validate validates the Conf.
+func (c Conf) Validate() error {
+	if len(c.Prefix) > 0 && (len(c.Key) > 0 || len(c.Keys) > 0) {
+		return errors.New("consul key and prefix are mutually exclusive")
+	}
+	if keys := c.keys(); len(keys) > 1 && len(commonPrefix(keys)) == 0 {
+		// the watch lists the common prefix, an empty one would be the whole KV store
+		return errors.New("consul keys must share a common prefix, e.g. put them under one folder")
+	}
+	if len(c.Token) > 0 && len(c.TokenFile) > 0 {
+		return errors.New("consul token and token file are mutually exclusive")
+	}
+	if len(c.SecretKeyFile) > 0 && len(c.SecretKeyEnv) > 0 {
+		return errors.New("consul secret key file and env are mutually exclusive")
+	}
+
+	return nil
+}
+
+// keys returns the KV documents in merge order.
+func (c Conf) keys() []string {
+	var keys []string
+	if len(c.Key) > 0 {
+		keys = append(keys, c.Key)
+	}
+	for _, key := range c.Keys {
+		if len(key) > 0 {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
 }
@@ -2,58 +2,138 @@ package consul
 
 import (
 	"bytes"
+	"context"
+	"crypto/cipher"
 	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
 	consulApi "github.com/hashicorp/consul/api"
 	"github.com/spf13/viper"
 	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/mathx"
 )
 
+const (
+	retryBaseInterval = time.Second
+	retryMaxInterval  = time.Minute
+	// maxDebounceRounds bounds the wait for a quiet period under constant writes.
+	maxDebounceRounds = 10
+)
+
+var retryJitter = mathx.NewUnstable(0.2)
+
 type (
 	// ConsulSubscriber is a subscriber that subscribes to Consul.
 	ConsulSubscriber struct {
 		consulCli *consulApi.Client
 		Path      string
+		Keys      []string
+		Prefix    string
 		listeners []func()
+		changes   []changeListener
 		lock      sync.Mutex
-		stopCh    chan struct{}
+		ctx       context.Context
+		cancel    context.CancelFunc
 		Type      string
+
+		waitTime time.Duration
+		debounce time.Duration
+		tokens   *tokenSource
+
+		validators []Validator
+		target     reflect.Type
+		aead       cipher.AEAD
+
+		snapshot  string
+		goodLock  sync.Mutex
+		lastGood  string
+		lastSaved string
+
+		// settings are the last accepted settings, owned by the watch goroutine.
+		settings map[string]any
+	}
+
+	// revision is a parsed and validated set of documents.
+	revision struct {
+		settings map[string]any
+		// content is the JSON returned by Value, with secrets decrypted.
+		content string
+		// sealed is the JSON saved to the snapshot, with secrets still encrypted.
+		sealed string
 	}
 
 	// ConsulConf is the configuration for Consul.
 	ConsulConf Conf
+
+	// Option customizes the subscriber during creation.
+	Option func(s *ConsulSubscriber)
 )
 
 // MustNewConsulSubscriber returns a Consul Subscriber, exits on errors.
-func MustNewConsulSubscriber(conf ConsulConf) *ConsulSubscriber {
-	s, err := NewConsulSubscriber(conf)
+func MustNewConsulSubscriber(conf ConsulConf, opts ...Option) *ConsulSubscriber {
+	s, err := NewConsulSubscriber(conf, opts...)
 	logx.Must(err)
 	return s
 }
 
 // NewConsulSubscriber returns a Consul Subscriber.
-func NewConsulSubscriber(conf ConsulConf) (*ConsulSubscriber, error) {
+func NewConsulSubscriber(conf ConsulConf, opts ...Option) (*ConsulSubscriber, error) {
+	if err := Conf(conf).Validate(); err != nil {
+		return nil, err
+	}
+
+	tokens, err := newTokenSource(conf.Token, conf.TokenFile)
+	if err != nil {
+		return nil, err
+	}
 
 	client, err := consulApi.NewClient(&consulApi.Config{
 		Address:    conf.Host,
 		Scheme:     conf.Scheme,
 		PathPrefix: conf.PathPrefix,
 		Datacenter: conf.Datacenter,
-		Token:      conf.Token,
+		Token:      tokens.Token(),
 		TLSConfig:  conf.TLSConfig,
+		Namespace:  conf.Namespace,
+		Partition:  conf.Partition,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	key, err := loadSecretKey(conf.SecretKeyFile, conf.SecretKeyEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := Conf(conf).keys()
 	subscriber := &ConsulSubscriber{
 		consulCli: client,
 		Path:      conf.Key,
-		stopCh:    make(chan struct{}),
+		Keys:      keys,
+		Prefix:    conf.Prefix,
 		Type:      conf.Type,
+		waitTime:  conf.WaitTime,
+		debounce:  conf.Debounce,
+		tokens:    tokens,
+		snapshot:  conf.SnapshotFile,
+	}
+	if len(subscriber.Path) == 0 && len(keys) > 0 {
+		subscriber.Path = keys[0]
+	}
+	if key != nil {
+		if subscriber.aead, err = newAEAD(key); err != nil {
+			return nil, err
+		}
+	}
+	for _, opt := range opts {
+		opt(subscriber)
 	}
+	subscriber.ctx, subscriber.cancel = context.WithCancel(context.Background())
 	go subscriber.watch()
 	return subscriber, nil
 }
@@ -67,53 +147,382 @@ func (s *ConsulSubscriber) AddListener(listener func()) error {
 	return nil
 }
 
-// Value returns the current value from Consul.
+// AddChangeListener adds a listener that receives the old and new settings and the changed paths.
+// With paths given, e.g. "Redis.*", the listener is only called when one of them changed.
+// Paths are matched case-insensitively since the parsed keys are lower-cased.
+func (s *ConsulSubscriber) AddChangeListener(listener ChangeListener, paths ...string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.changes = append(s.changes, newChangeListener(listener, paths))
+	return nil
+}
+
+// Value returns the current value from Consul, or from the local snapshot if Consul is unreachable.
+// With several keys or a prefix, the documents are deep-merged in order.
+// A revision rejected by the validation pipeline yields the last good value instead.
 func (s *ConsulSubscriber) Value() (string, error) {
-	defaultConfig := viper.New()
-	defaultConfig.SetConfigType(s.Type)
-	kvPair, _, err := s.consulCli.KV().Get(s.Path, nil)
+	pairs, _, err := s.fetch(s.queryOptions())
 	if err != nil {
-		return "", err
+		return s.fallback(err)
+	}
+	if len(s.snapshot) > 0 {
+		metricSnapshotStale.Set(0, s.name())
 	}
-	if kvPair == nil {
+	if len(pairs) == 0 {
 		return "", nil
 	}
-	err = defaultConfig.ReadConfig(bytes.NewBuffer(kvPair.Value))
+
+	rev, err := s.decode(pairs)
+	if err != nil {
+		return s.keepGood(err)
+	}
+	s.accept(rev)
+	return rev.content, nil
+}
+
+// decode parses, merges, decrypts and validates the documents.
+func (s *ConsulSubscriber) decode(pairs consulApi.KVPairs) (revision, error) {
+	settings, err := s.parse(pairs)
+	if err != nil {
+		return revision{}, err
+	}
+
+	sealed, _ := json.Marshal(settings)
+	if settings, err = decryptSettings(s.aead, settings); err != nil {
+		return revision{}, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	marshal, _ := json.Marshal(settings)
+	if err = s.validate(settings, marshal); err != nil {
+		return revision{}, err
+	}
+
+	return revision{
+		settings: settings,
+		content:  string(marshal),
+		sealed:   string(sealed),
+	}, nil
+}
+
+// keepGood serves the last good value, or the local snapshot, for a rejected revision.
+func (s *ConsulSubscriber) keepGood(err error) (string, error) {
+	s.goodLock.Lock()
+	good := s.lastGood
+	s.goodLock.Unlock()
+	if len(good) == 0 {
+		return s.fallback(err)
+	}
+
+	logx.Errorf("consul config %s rejected, keeping the last good value: %v", s.name(), err)
+	return good, nil
+}
+
+// fallback serves the local snapshot when reading from Consul failed with err.
+func (s *ConsulSubscriber) fallback(err error) (string, error) {
+	sealed, ok := readSnapshot(s.snapshot)
+	if !ok {
+		return "", err
+	}
+
+	snapshot, decErr := s.unseal(sealed)
+	if decErr != nil {
+		logx.Errorf("decrypt consul snapshot %s failed: %v", s.snapshot, decErr)
+		return "", err
+	}
+
+	logx.Errorf("read consul config %s failed, using stale local snapshot %s: %v", s.name(), s.snapshot, err)
+	metricSnapshotFallbackTotal.Inc(s.name())
+	metricSnapshotStale.Set(1, s.name())
+	return snapshot, nil
+}
+
+// unseal decrypts the secrets of a snapshot, which keeps them encrypted on disk.
+func (s *ConsulSubscriber) unseal(sealed string) (string, error) {
+	if !strings.Contains(sealed, secretPrefix) {
+		return sealed, nil
+	}
+
+	var settings map[string]any
+	if err := json.Unmarshal([]byte(sealed), &settings); err != nil {
+		return "", err
+	}
+	settings, err := decryptSettings(s.aead, settings)
 	if err != nil {
 		return "", err
 	}
-	settings := defaultConfig.AllSettings()
+
 	marshal, _ := json.Marshal(settings)
 	return string(marshal), nil
 }
 
+// accept records rev as the last good value and writes it to the
+// snapshot file if it changed since the last write.
+func (s *ConsulSubscriber) accept(rev revision) {
+	s.goodLock.Lock()
+	defer s.goodLock.Unlock()
+	s.lastGood = rev.content
+	if len(s.snapshot) == 0 || rev.sealed == s.lastSaved {
+		return
+	}
+
+	if err := writeSnapshot(s.snapshot, rev.sealed); err != nil {
+		logx.Errorf("save consul snapshot %s failed: %v", s.snapshot, err)
+		return
+	}
+	s.lastSaved = rev.sealed
+}
+
+// reject records a revision rejected by the validation pipeline.
+func (s *ConsulSubscriber) reject(index uint64, err error) {
+	logx.Errorf("consul config %s at index %d rejected, keeping the last good value: %v", s.name(), index, err)
+	metricRejectedTotal.Inc(s.name())
+}
+
+// name identifies the watched documents in logs and metrics.
+func (s *ConsulSubscriber) name() string {
+	if len(s.Prefix) > 0 {
+		return s.Prefix
+	}
+
+	return strings.Join(s.Keys, ",")
+}
+
+// fetch reads the watched KV documents in merge order. A 403 is retried once
+// if the token file was rotated since the token was last read.
+func (s *ConsulSubscriber) fetch(q *consulApi.QueryOptions) (consulApi.KVPairs, *consulApi.QueryMeta, error) {
+	pairs, meta, err := s.read(q)
+	if !isForbidden(err) {
+		return pairs, meta, err
+	}
+
+	if reloadErr := s.tokens.reload(); reloadErr != nil {
+		logx.Errorf("reload consul token file for %s failed: %v", s.name(), reloadErr)
+	}
+	// compare with the token of the request, another read may have reloaded it already
+	token := s.tokens.Token()
+	if token == q.Token {
+		return pairs, meta, err
+	}
+
+	logx.Infof("consul token for %s was rotated, retrying with the new token", s.name())
+	q.Token = token
+	return s.read(q)
+}
+
+// read reads the watched KV documents in merge order.
+func (s *ConsulSubscriber) read(q *consulApi.QueryOptions) (consulApi.KVPairs, *consulApi.QueryMeta, error) {
+	kv := s.consulCli.KV()
+	switch {
+	case len(s.Prefix) > 0:
+		pairs, meta, err := kv.List(s.Prefix, q)
+		if err != nil {
+			return nil, nil, err
+		}
+		return documents(pairs), meta, nil
+	case len(s.Keys) > 1:
+		// one list query over the shared prefix watches all keys at once
+		pairs, meta, err := kv.List(commonPrefix(s.Keys), q)
+		if err != nil {
+			return nil, nil, err
+		}
+		return pickKeys(pairs, s.Keys), meta, nil
+	default:
+		pair, meta, err := kv.Get(s.Path, q)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pair == nil {
+			return nil, meta, nil
+		}
+		return consulApi.KVPairs{pair}, meta, nil
+	}
+}
+
+// parse parses each document with the configured type and deep-merges them.
+func (s *ConsulSubscriber) parse(pairs consulApi.KVPairs) (map[string]any, error) {
+	var settings map[string]any
+	for _, pair := range pairs {
+		v := viper.New()
+		v.SetConfigType(s.Type)
+		if err := v.ReadConfig(bytes.NewBuffer(pair.Value)); err != nil {
+			return nil, fmt.Errorf("parse consul key %s: %w", pair.Key, err)
+		}
+		settings = MergeSettings(settings, v.AllSettings())
+	}
+
+	return settings, nil
+}
+
 // watch monitors the Consul KV for changes and triggers listeners.
 func (s *ConsulSubscriber) watch() {
-	_, meta, err := s.consulCli.KV().Get(s.Path, nil)
 	var lastIndex uint64
+	var lastVersion string
+	var failures int
+	pairs, meta, err := s.fetch(s.watchOptions(0, 0))
 	if err == nil && meta != nil {
+		metricWatchLastSuccess.Set(float64(time.Now().Unix()), s.name())
 		lastIndex = meta.LastIndex
+		lastVersion = version(pairs)
+		if len(pairs) > 0 {
+			if rev, err := s.decode(pairs); err != nil {
+				s.reject(modifyIndex(pairs, meta), err)
+			} else {
+				s.settings = rev.settings
+				s.accept(rev)
+			}
+		}
 	}
 	for {
-		select {
-		case <-s.stopCh:
+		pairs, meta, err := s.fetch(s.watchOptions(lastIndex, s.waitTime))
+		if s.ctx.Err() != nil {
 			return
-		default:
-			_, meta, err := s.consulCli.KV().Get(s.Path, &consulApi.QueryOptions{
-				WaitIndex: lastIndex,
-			})
-			if err != nil {
-				time.Sleep(time.Second) // Retry after a delay
-				continue
-			}
-			if meta.LastIndex > lastIndex {
-				lastIndex = meta.LastIndex
-				s.notifyListeners()
+		}
+		if err != nil {
+			failures++
+			metricWatchErrorsTotal.Inc(s.name())
+			delay := retryDelay(failures)
+			logx.Errorf("watch consul config %s failed, retry in %s: %v", s.name(), delay, err)
+			if !s.sleep(delay) {
+				return
 			}
+			continue
+		}
+
+		failures = 0
+		metricWatchLastSuccess.Set(float64(time.Now().Unix()), s.name())
+		lastIndex = nextIndex(lastIndex, meta.LastIndex)
+		if version(pairs) == lastVersion {
+			continue
+		}
+
+		pairs, meta = s.settle(pairs, meta)
+		if s.ctx.Err() != nil {
+			return
 		}
+		lastIndex = nextIndex(lastIndex, meta.LastIndex)
+		lastVersion = version(pairs)
+		metricWatchReloadsTotal.Inc(s.name())
+		s.apply(pairs, meta)
 	}
 }
 
+// settle waits until the documents stay unchanged for the debounce interval,
+// so that a burst of KV writes results in a single reload.
+func (s *ConsulSubscriber) settle(pairs consulApi.KVPairs, meta *consulApi.QueryMeta) (
+	consulApi.KVPairs, *consulApi.QueryMeta) {
+	if s.debounce <= 0 {
+		return pairs, meta
+	}
+
+	ver := version(pairs)
+	for i := 0; i < maxDebounceRounds; i++ {
+		// a blocking query returns early on a change, or after the debounce interval
+		next, nextMeta, err := s.fetch(s.watchOptions(meta.LastIndex, s.debounce))
+		if err != nil {
+			return pairs, meta
+		}
+
+		nextVer := version(next)
+		pairs, meta = next, nextMeta
+		if nextVer == ver {
+			break
+		}
+		ver = nextVer
+	}
+
+	return pairs, meta
+}
+
+// queryOptions returns the options of KV reads, with the current token.
+func (s *ConsulSubscriber) queryOptions() *consulApi.QueryOptions {
+	return &consulApi.QueryOptions{
+		Token: s.tokens.Token(),
+	}
+}
+
+// watchOptions returns the options of a blocking query, which is canceled by Stop.
+func (s *ConsulSubscriber) watchOptions(index uint64, wait time.Duration) *consulApi.QueryOptions {
+	q := s.queryOptions()
+	q.WaitIndex = index
+	q.WaitTime = wait
+	return q.WithContext(s.ctx)
+}
+
+// sleep waits for d, it returns false if the subscriber is stopped meanwhile.
+func (s *ConsulSubscriber) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// nextIndex returns the index of the next blocking query. An index that went
+// backwards, e.g. after a Consul snapshot restore, restarts the watch from 0.
+func nextIndex(last, current uint64) uint64 {
+	if current < last {
+		return 0
+	}
+
+	return current
+}
+
+// retryDelay returns the exponential backoff with jitter after failures consecutive errors.
+func retryDelay(failures int) time.Duration {
+	delay := retryBaseInterval
+	for i := 1; i < failures && delay < retryMaxInterval; i++ {
+		delay *= 2
+	}
+
+	return retryJitter.AroundDuration(min(delay, retryMaxInterval))
+}
+
+// apply handles a change of the watched documents. A revision that fails to
+// parse or validate is rejected and nobody is notified; otherwise it's saved
+// to the snapshot, even if no listener reads the value, and all listeners are notified.
+func (s *ConsulSubscriber) apply(pairs consulApi.KVPairs, meta *consulApi.QueryMeta) {
+	var settings map[string]any
+	if len(pairs) > 0 {
+		rev, err := s.decode(pairs)
+		if err != nil {
+			s.reject(modifyIndex(pairs, meta), err)
+			return
+		}
+		s.accept(rev)
+		settings = rev.settings
+	}
+
+	event := ChangeEvent{
+		Old:         s.settings,
+		New:         settings,
+		Changed:     diffSettings(s.settings, settings),
+		ModifyIndex: modifyIndex(pairs, meta),
+	}
+	s.settings = settings
+	s.notifyListeners()
+	if len(event.Changed) > 0 {
+		s.notifyChangeListeners(event)
+	}
+}
+
+// modifyIndex returns the highest ModifyIndex of pairs, or the index of the
+// query when all documents were deleted.
+func modifyIndex(pairs consulApi.KVPairs, meta *consulApi.QueryMeta) uint64 {
+	var index uint64
+	for _, pair := range pairs {
+		index = max(index, pair.ModifyIndex)
+	}
+	if index == 0 && meta != nil {
+		index = meta.LastIndex
+	}
+
+	return index
+}
+
 // notifyListeners calls all registered listeners.
 func (s *ConsulSubscriber) notifyListeners() {
 	s.lock.Lock()
@@ -123,7 +532,18 @@ func (s *ConsulSubscriber) notifyListeners() {
 	}
 }
 
-// Stop stops the watch process.
+// notifyChangeListeners calls the change listeners subscribed to the changed paths.
+func (s *ConsulSubscriber) notifyChangeListeners(event ChangeEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, l := range s.changes {
+		if l.wants(event.Changed) {
+			l.listener(event)
+		}
+	}
+}
+
+// Stop stops the watch process and cancels the in-flight blocking query.
 func (s *ConsulSubscriber) Stop() {
-	close(s.stopCh)
+	s.cancel()
 }
@@ -3,9 +3,16 @@ package consul
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -24,18 +31,24 @@ type kvPair struct {
 
 type mockConsulServer struct {
 	*httptest.Server
-	mu        sync.Mutex
-	index     uint64
-	value     []byte
-	key       string
-	changed   chan struct{}
+	mu           sync.Mutex
+	index        uint64
+	values       map[string][]byte
+	modify       map[string]uint64
+	key          string
+	changed      chan struct{}
 	requestCount int32
+	canceled     int32
+	failures     int32
+	token        atomic.Value
+	lastQuery    atomic.Value
 }
 
 func newMockConsulServer(t *testing.T, key string, initial []byte) *mockConsulServer {
 	m := &mockConsulServer{
 		index:   100,
-		value:   initial,
+		values:  map[string][]byte{key: initial},
+		modify:  map[string]uint64{key: 100},
 		key:     key,
 		changed: make(chan struct{}),
 	}
@@ -47,40 +60,86 @@ func newMockConsulServer(t *testing.T, key string, initial []byte) *mockConsulSe
 func (m *mockConsulServer) handleKV(w http.ResponseWriter, r *http.Request) {
 	atomic.AddInt32(&m.requestCount, 1)
 
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	_, recurse := r.URL.Query()["recurse"]
 	waitIndexStr := r.URL.Query().Get("index")
 	waitIndex, _ := strconv.ParseUint(waitIndexStr, 10, 64)
+	wait := 200 * time.Millisecond
+	if d, err := time.ParseDuration(r.URL.Query().Get("wait")); err == nil {
+		wait = d
+	}
+
+	if atomic.AddInt32(&m.failures, -1) >= 0 {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	m.lastQuery.Store(r.URL.Query())
+	if token, ok := m.token.Load().(string); ok && r.Header.Get("X-Consul-Token") != token {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("ACL not found"))
+		return
+	}
 
 	m.mu.Lock()
 	currentIndex := m.index
-	currentValue := m.value
 	changed := m.changed
 	m.mu.Unlock()
 
 	if waitIndex > 0 && waitIndex == currentIndex {
 		select {
 		case <-changed:
-			m.mu.Lock()
-			currentIndex = m.index
-			currentValue = m.value
-			m.mu.Unlock()
-		case <-time.After(200 * time.Millisecond):
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			atomic.AddInt32(&m.canceled, 1)
+			return
 		}
 	}
 
+	m.mu.Lock()
+	currentIndex = m.index
+	pairs := m.pairs(key, recurse)
+	m.mu.Unlock()
+
 	w.Header().Set("X-Consul-Index", strconv.FormatUint(currentIndex, 10))
+	if len(pairs) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode([]kvPair{{
-		Key:         m.key,
-		Value:       base64.StdEncoding.EncodeToString(currentValue),
-		CreateIndex: currentIndex,
-		ModifyIndex: currentIndex,
-	}})
+	_ = json.NewEncoder(w).Encode(pairs)
+}
+
+// pairs must be called with m.mu held.
+func (m *mockConsulServer) pairs(key string, recurse bool) []kvPair {
+	var keys []string
+	for k := range m.values {
+		if k == key || recurse && strings.HasPrefix(k, key) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]kvPair, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, kvPair{
+			Key:         k,
+			Value:       base64.StdEncoding.EncodeToString(m.values[k]),
+			CreateIndex: m.modify[k],
+			ModifyIndex: m.modify[k],
+		})
+	}
+	return pairs
 }
 
 func (m *mockConsulServer) updateValue(value []byte) {
+	m.setValue(m.key, value)
+}
+
+func (m *mockConsulServer) setValue(key string, value []byte) {
 	m.mu.Lock()
 	m.index++
-	m.value = value
+	m.values[key] = value
+	m.modify[key] = m.index
 	close(m.changed)
 	m.changed = make(chan struct{})
 	m.mu.Unlock()
@@ -369,3 +428,899 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestConf_Validate(t *testing.T) {
+	if err := (Conf{Key: "app/a", Keys: []string{"app/b"}}).Validate(); err != nil {
+		t.Fatalf("Validate() error: %v", err)
+	}
+	if err := (Conf{Key: "a", Keys: []string{"b"}}).Validate(); err == nil {
+		t.Fatal("expected error when keys share no common prefix")
+	}
+	if err := (Conf{Prefix: "app/"}).Validate(); err != nil {
+		t.Fatalf("Validate() error: %v", err)
+	}
+	if err := (Conf{Key: "a", Prefix: "app/"}).Validate(); err == nil {
+		t.Fatal("expected error when both key and prefix are set")
+	}
+
+	_, err := NewConsulSubscriber(ConsulConf{
+		Host:   "127.0.0.1:8500",
+		Scheme: "http",
+		Keys:   []string{"a"},
+		Prefix: "app/",
+		Type:   "yaml",
+	})
+	if err == nil {
+		t.Fatal("expected error for keys with prefix")
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		keys []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"app/common"}, "app/common"},
+		{[]string{"app/common", "app/order", "app/order.prod"}, "app/"},
+		{[]string{"common", "order"}, ""},
+	}
+	for _, tt := range tests {
+		if got := commonPrefix(tt.keys); got != tt.want {
+			t.Errorf("commonPrefix(%v) = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestMergeSettings(t *testing.T) {
+	dst := map[string]any{
+		"name": "common",
+		"redis": map[string]any{
+			"host": "127.0.0.1:6379",
+			"type": "node",
+		},
+		"hosts": []any{"a", "b"},
+	}
+	src := map[string]any{
+		"redis": map[string]any{
+			"host": "redis:6379",
+		},
+		"hosts": []any{"c"},
+		"port":  8080,
+	}
+
+	got := MergeSettings(dst, src)
+	redis := got["redis"].(map[string]any)
+	if redis["host"] != "redis:6379" || redis["type"] != "node" {
+		t.Errorf("redis = %v, want host overridden and type kept", redis)
+	}
+	if hosts := got["hosts"].([]any); len(hosts) != 1 || hosts[0] != "c" {
+		t.Errorf("hosts = %v, want [c]", hosts)
+	}
+	if got["name"] != "common" || got["port"] != 8080 {
+		t.Errorf("merged = %v", got)
+	}
+}
+
+func TestValue_MergeKeys(t *testing.T) {
+	server := newMockConsulServer(t, "app/common", []byte("name: common\nredis:\n  host: 127.0.0.1:6379\n  type: node"))
+	server.setValue("app/order", []byte("name: order\nredis:\n  host: redis:6379"))
+	server.setValue("app/order.prod", []byte("port: 8080"))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:   server.URL,
+		Scheme: "http",
+		Key:    "app/common",
+		Keys:   []string{"app/order", "app/missing", "app/order.prod"},
+		Type:   "yaml",
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal([]byte(v), &got); err != nil {
+		t.Fatalf("unmarshal %q: %v", v, err)
+	}
+	if got["name"] != "order" {
+		t.Errorf("name = %v, want order", got["name"])
+	}
+	if got["port"] != float64(8080) {
+		t.Errorf("port = %v, want 8080", got["port"])
+	}
+	redis := got["redis"].(map[string]any)
+	if redis["host"] != "redis:6379" || redis["type"] != "node" {
+		t.Errorf("redis = %v, want merged", redis)
+	}
+}
+
+func TestValue_Prefix(t *testing.T) {
+	server := newMockConsulServer(t, "app/10-order", []byte(`{"name":"order"}`))
+	server.setValue("app/", nil)
+	server.setValue("app/00-common", []byte(`{"name":"common","timeout":3000}`))
+	server.setValue("other/key", []byte(`{"name":"other"}`))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:   server.URL,
+		Scheme: "http",
+		Prefix: "app/",
+		Type:   "json",
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if v != `{"name":"order","timeout":3000}` {
+		t.Errorf("Value() = %q", v)
+	}
+}
+
+func TestValue_PrefixEmpty(t *testing.T) {
+	server := newMockConsulServer(t, "other/key", []byte(`{"name":"other"}`))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:   server.URL,
+		Scheme: "http",
+		Prefix: "app/",
+		Type:   "json",
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if v != "" {
+		t.Errorf("Value() = %q, want empty string", v)
+	}
+}
+
+func TestWatch_MergeKeysTriggersListener(t *testing.T) {
+	server := newMockConsulServer(t, "app/common", []byte("name: common"))
+	server.setValue("app/order", []byte("name: order"))
+	server.setValue("app/unrelated", []byte("name: unrelated"))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:   server.URL,
+		Scheme: "http",
+		Keys:   []string{"app/common", "app/order"},
+		Type:   "yaml",
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	var calls int32
+	if err := sub.AddListener(func() {
+		atomic.AddInt32(&calls, 1)
+	}); err != nil {
+		t.Fatalf("AddListener error: %v", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	// A change to a key outside the watched set must not notify.
+	server.setValue("app/unrelated", []byte("name: changed"))
+	time.Sleep(300 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Fatalf("listener called %d times for an unrelated key", n)
+	}
+
+	server.setValue("app/order", []byte("name: jerry"))
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&calls) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("watch did not trigger listener after a watched key changed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestValue_SnapshotFallback(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "snapshot", "app.json")
+	server := newMockConsulServer(t, "test/key", []byte("name: tom"))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:         server.URL,
+		Scheme:       "http",
+		Key:          "test/key",
+		Type:         "yaml",
+		SnapshotFile: snapshot,
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	want, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	saved, err := os.ReadFile(snapshot)
+	if err != nil {
+		t.Fatalf("snapshot not written: %v", err)
+	}
+	if string(saved) != want {
+		t.Errorf("snapshot = %q, want %q", saved, want)
+	}
+
+	server.Close()
+
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() with Consul down error: %v", err)
+	}
+	if v != want {
+		t.Errorf("Value() = %q, want snapshot %q", v, want)
+	}
+}
+
+func TestValue_SnapshotAtStartup(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "app.json")
+	if err := os.WriteFile(snapshot, []byte(`{"name":"tom"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:         server.URL,
+		Scheme:       "http",
+		Key:          "test/key",
+		Type:         "yaml",
+		SnapshotFile: snapshot,
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if v != `{"name":"tom"}` {
+		t.Errorf("Value() = %q, want snapshot", v)
+	}
+}
+
+func TestValue_SnapshotMissing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:         server.URL,
+		Scheme:       "http",
+		Key:          "test/key",
+		Type:         "yaml",
+		SnapshotFile: filepath.Join(t.TempDir(), "missing.json"),
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	if _, err = sub.Value(); err == nil {
+		t.Fatal("expected error without a snapshot")
+	}
+}
+
+func TestWatch_RefreshesSnapshot(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "app.json")
+	server := newMockConsulServer(t, "test/key", []byte("name: tom"))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:         server.URL,
+		Scheme:       "http",
+		Key:          "test/key",
+		Type:         "yaml",
+		SnapshotFile: snapshot,
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	time.Sleep(300 * time.Millisecond)
+	server.updateValue([]byte("name: jerry"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		saved, _ := os.ReadFile(snapshot)
+		if string(saved) == `{"name":"jerry"}` {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("snapshot = %q, want the changed value", saved)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatch_ChangeListener(t *testing.T) {
+	server := newMockConsulServer(t, "test/key", []byte("name: tom\nredis:\n  host: 127.0.0.1:6379"))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:   server.URL,
+		Scheme: "http",
+		Key:    "test/key",
+		Type:   "yaml",
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	events := make(chan ChangeEvent, 4)
+	if err := sub.AddChangeListener(func(event ChangeEvent) {
+		events <- event
+	}); err != nil {
+		t.Fatalf("AddChangeListener error: %v", err)
+	}
+	redisEvents := make(chan ChangeEvent, 4)
+	if err := sub.AddChangeListener(func(event ChangeEvent) {
+		redisEvents <- event
+	}, "Redis.*"); err != nil {
+		t.Fatalf("AddChangeListener error: %v", err)
+	}
+	plain := make(chan struct{}, 4)
+	if err := sub.AddListener(func() {
+		plain <- struct{}{}
+	}); err != nil {
+		t.Fatalf("AddListener error: %v", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	server.updateValue([]byte("name: jerry\nredis:\n  host: 127.0.0.1:6379"))
+
+	select {
+	case event := <-events:
+		if !reflect.DeepEqual(event.Changed, []string{"name"}) {
+			t.Errorf("Changed = %v, want [name]", event.Changed)
+		}
+		if event.Old["name"] != "tom" || event.New["name"] != "jerry" {
+			t.Errorf("Old = %v, New = %v", event.Old, event.New)
+		}
+		if event.ModifyIndex != 101 {
+			t.Errorf("ModifyIndex = %d, want 101", event.ModifyIndex)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("change listener was not called")
+	}
+	select {
+	case <-plain:
+	case <-time.After(time.Second):
+		t.Fatal("plain listener was not called")
+	}
+	select {
+	case event := <-redisEvents:
+		t.Fatalf("redis listener called for %v", event.Changed)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	server.updateValue([]byte("name: jerry\nredis:\n  host: redis:6379"))
+
+	select {
+	case event := <-redisEvents:
+		if !reflect.DeepEqual(event.Changed, []string{"redis.host"}) {
+			t.Errorf("Changed = %v, want [redis.host]", event.Changed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("redis listener was not called")
+	}
+}
+
+func TestWatch_ChangeListenerSkipsInvalid(t *testing.T) {
+	server := newMockConsulServer(t, "test/key", []byte("name: tom"))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:   server.URL,
+		Scheme: "http",
+		Key:    "test/key",
+		Type:   "yaml",
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	events := make(chan ChangeEvent, 4)
+	if err := sub.AddChangeListener(func(event ChangeEvent) {
+		events <- event
+	}); err != nil {
+		t.Fatalf("AddChangeListener error: %v", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	server.updateValue([]byte("{invalid yaml: "))
+	time.Sleep(300 * time.Millisecond)
+	server.updateValue([]byte("name: jerry"))
+
+	select {
+	case event := <-events:
+		// the invalid revision is skipped, the diff is against the last parsed settings
+		if event.Old["name"] != "tom" || event.New["name"] != "jerry" {
+			t.Errorf("Old = %v, New = %v", event.Old, event.New)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("change listener was not called")
+	}
+}
+
+func TestValue_Validation(t *testing.T) {
+	server := newMockConsulServer(t, "test/key", []byte("name: tom"))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:   server.URL,
+		Scheme: "http",
+		Key:    "test/key",
+		Type:   "yaml",
+	}, WithValidator(func(settings map[string]any) error {
+		if settings["name"] == "" || settings["name"] == nil {
+			return errors.New("empty name")
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	want, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+
+	server.updateValue([]byte("name: ''"))
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if v != want {
+		t.Errorf("Value() = %q, want the last good value %q", v, want)
+	}
+}
+
+func TestValue_ValidationWithoutGoodValue(t *testing.T) {
+	server := newMockConsulServer(t, "test/key", []byte("port: 8080"))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:   server.URL,
+		Scheme: "http",
+		Key:    "test/key",
+		Type:   "yaml",
+	}, WithTarget(validateTarget{}))
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	_, err = sub.Value()
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("Value() error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestWatch_RejectsInvalidRevision(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "app.json")
+	server := newMockConsulServer(t, "test/key", []byte("name: tom\nport: 8080"))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:         server.URL,
+		Scheme:       "http",
+		Key:          "test/key",
+		Type:         "yaml",
+		SnapshotFile: snapshot,
+	}, WithTarget(&validateTarget{}))
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	plain := make(chan struct{}, 4)
+	if err := sub.AddListener(func() {
+		plain <- struct{}{}
+	}); err != nil {
+		t.Fatalf("AddListener error: %v", err)
+	}
+	events := make(chan ChangeEvent, 4)
+	if err := sub.AddChangeListener(func(event ChangeEvent) {
+		events <- event
+	}); err != nil {
+		t.Fatalf("AddChangeListener error: %v", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	server.updateValue([]byte("name: tom\nport: 70000"))
+
+	select {
+	case <-plain:
+		t.Fatal("plain listener notified of a rejected revision")
+	case event := <-events:
+		t.Fatalf("change listener notified of a rejected revision: %v", event.Changed)
+	case <-time.After(500 * time.Millisecond):
+	}
+	saved, _ := os.ReadFile(snapshot)
+	if string(saved) != `{"name":"tom","port":8080}` {
+		t.Errorf("snapshot = %q, want the last good value", saved)
+	}
+
+	server.updateValue([]byte("name: jerry\nport: 8080"))
+
+	select {
+	case event := <-events:
+		if event.Old["name"] != "tom" || event.New["name"] != "jerry" {
+			t.Errorf("Old = %v, New = %v", event.Old, event.New)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("change listener was not called for a valid revision")
+	}
+	select {
+	case <-plain:
+	case <-time.After(time.Second):
+		t.Fatal("plain listener was not called for a valid revision")
+	}
+}
+
+func TestValue_EncryptedSecrets(t *testing.T) {
+	text, key := newTestKey(t)
+	t.Setenv("CONSUL_TEST_SECRET_KEY", text)
+	password, _ := EncryptValue(key, "p@ssw0rd")
+	snapshot := filepath.Join(t.TempDir(), "app.json")
+	server := newMockConsulServer(t, "test/key", []byte("mysql:\n  password: "+password))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:         server.URL,
+		Scheme:       "http",
+		Key:          "test/key",
+		Type:         "yaml",
+		SnapshotFile: snapshot,
+		SecretKeyEnv: "CONSUL_TEST_SECRET_KEY",
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if v != `{"mysql":{"password":"p@ssw0rd"}}` {
+		t.Errorf("Value() = %q, want the decrypted password", v)
+	}
+
+	// the snapshot keeps the secret encrypted
+	saved, _ := os.ReadFile(snapshot)
+	if strings.Contains(string(saved), "p@ssw0rd") || !strings.Contains(string(saved), password) {
+		t.Errorf("snapshot = %q, want the encrypted password", saved)
+	}
+
+	// a value that cannot be decrypted fails validation and keeps the last good value
+	server.updateValue([]byte("mysql:\n  password: ENC[AES256_GCM,broken]"))
+	if got, err := sub.Value(); err != nil || got != v {
+		t.Errorf("Value() = %q, %v, want the last good value", got, err)
+	}
+
+	server.Close()
+	if got, err := sub.Value(); err != nil || got != v {
+		t.Errorf("Value() from snapshot = %q, %v, want the decrypted password", got, err)
+	}
+}
+
+func TestValue_EncryptedWithoutKey(t *testing.T) {
+	_, key := newTestKey(t)
+	password, _ := EncryptValue(key, "p@ssw0rd")
+	server := newMockConsulServer(t, "test/key", []byte("mysql:\n  password: "+password))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:   server.URL,
+		Scheme: "http",
+		Key:    "test/key",
+		Type:   "yaml",
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	_, err = sub.Value()
+	if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), ErrNoSecretKey.Error()) {
+		t.Errorf("Value() error = %v, want ErrInvalidConfig for a missing key", err)
+	}
+}
+
+func TestNewConsulSubscriber_SecretKeyErrors(t *testing.T) {
+	_, err := NewConsulSubscriber(ConsulConf{
+		Host:          "127.0.0.1:8500",
+		Scheme:        "http",
+		Key:           "test/key",
+		Type:          "yaml",
+		SecretKeyFile: filepath.Join(t.TempDir(), "missing.key"),
+	})
+	if err == nil {
+		t.Error("expected error for a missing key file")
+	}
+
+	t.Setenv("CONSUL_TEST_SECRET_KEY", "c2hvcnQ=")
+	_, err = NewConsulSubscriber(ConsulConf{
+		Host:         "127.0.0.1:8500",
+		Scheme:       "http",
+		Key:          "test/key",
+		Type:         "yaml",
+		SecretKeyEnv: "CONSUL_TEST_SECRET_KEY",
+	})
+	if !errors.Is(err, ErrInvalidSecretKey) {
+		t.Errorf("NewConsulSubscriber error = %v, want ErrInvalidSecretKey", err)
+	}
+
+	if err := (Conf{SecretKeyFile: "a", SecretKeyEnv: "B"}).Validate(); err == nil {
+		t.Error("expected error when both key file and env are set")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := retryDelay(tt.failures)
+			if got < tt.want*8/10 || got > tt.want*12/10 {
+				t.Fatalf("retryDelay(%d) = %s, want %s ±20%%", tt.failures, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestNextIndex(t *testing.T) {
+	if got := nextIndex(100, 101); got != 101 {
+		t.Errorf("nextIndex(100, 101) = %d, want 101", got)
+	}
+	if got := nextIndex(100, 100); got != 100 {
+		t.Errorf("nextIndex(100, 100) = %d, want 100", got)
+	}
+	if got := nextIndex(100, 5); got != 0 {
+		t.Errorf("nextIndex(100, 5) = %d, want 0 after a reset", got)
+	}
+}
+
+func TestWatch_Debounce(t *testing.T) {
+	server := newMockConsulServer(t, "test/key", []byte("name: tom"))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:     server.URL,
+		Scheme:   "http",
+		Key:      "test/key",
+		Type:     "yaml",
+		Debounce: 150 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	events := make(chan ChangeEvent, 4)
+	if err := sub.AddChangeListener(func(event ChangeEvent) {
+		events <- event
+	}); err != nil {
+		t.Fatalf("AddChangeListener error: %v", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	for _, name := range []string{"a", "b", "c"} {
+		server.updateValue([]byte("name: " + name))
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	select {
+	case event := <-events:
+		if event.Old["name"] != "tom" || event.New["name"] != "c" {
+			t.Errorf("Old = %v, New = %v, want tom -> c", event.Old, event.New)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("change listener was not called")
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("burst caused another reload: %v -> %v", event.Old, event.New)
+	case <-time.After(400 * time.Millisecond):
+	}
+}
+
+func TestWatch_RetriesAfterErrors(t *testing.T) {
+	server := newMockConsulServer(t, "test/key", []byte("name: tom"))
+	atomic.StoreInt32(&server.failures, 2)
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:   server.URL,
+		Scheme: "http",
+		Key:    "test/key",
+		Type:   "yaml",
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	called := make(chan struct{}, 1)
+	if err := sub.AddListener(func() {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+	}); err != nil {
+		t.Fatalf("AddListener error: %v", err)
+	}
+
+	// the initial and the first blocking query fail, the retry after about 1s
+	// succeeds and picks up the value as a change
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not recover after errors")
+	}
+}
+
+func TestStop_CancelsBlockingQuery(t *testing.T) {
+	server := newMockConsulServer(t, "test/key", []byte("name: tom"))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:     server.URL,
+		Scheme:   "http",
+		Key:      "test/key",
+		Type:     "yaml",
+		WaitTime: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+
+	// let the watch enter its blocking query
+	time.Sleep(300 * time.Millisecond)
+	sub.Stop()
+	sub.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&server.canceled) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Stop did not cancel the in-flight blocking query")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestValue_NamespaceAndPartition(t *testing.T) {
+	server := newMockConsulServer(t, "test/key", []byte("name: tom"))
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:      server.URL,
+		Scheme:    "http",
+		Key:       "test/key",
+		Type:      "yaml",
+		Namespace: "order",
+		Partition: "team-a",
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	if _, err = sub.Value(); err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	query := server.lastQuery.Load().(url.Values)
+	if query.Get("ns") != "order" || query.Get("partition") != "team-a" {
+		t.Errorf("query = %v, want ns=order and partition=team-a", query)
+	}
+}
+
+func TestValue_TokenRotation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(file)
+	server := newMockConsulServer(t, "test/key", []byte("name: tom"))
+	server.token.Store("old")
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:      server.URL,
+		Scheme:    "http",
+		Key:       "test/key",
+		Type:      "yaml",
+		TokenFile: file,
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	called := make(chan struct{}, 1)
+	if err := sub.AddListener(func() {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+	}); err != nil {
+		t.Fatalf("AddListener error: %v", err)
+	}
+	if _, err = sub.Value(); err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+
+	// rotate the token, keeping the modification time so only the 403 reveals it
+	server.token.Store("new")
+	if err := os.WriteFile(file, []byte("new"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() after rotation error: %v", err)
+	}
+	if v != `{"name":"tom"}` {
+		t.Errorf("Value() = %q", v)
+	}
+
+	server.updateValue([]byte("name: jerry"))
+	select {
+	case <-called:
+	case <-time.After(3 * time.Second):
+		t.Fatal("watch did not recover after the token rotation")
+	}
+}
+
+func TestValue_Forbidden(t *testing.T) {
+	server := newMockConsulServer(t, "test/key", []byte("name: tom"))
+	server.token.Store("expected")
+
+	sub, err := NewConsulSubscriber(ConsulConf{
+		Host:   server.URL,
+		Scheme: "http",
+		Key:    "test/key",
+		Type:   "yaml",
+		Token:  "wrong",
+	})
+	if err != nil {
+		t.Fatalf("NewConsulSubscriber error: %v", err)
+	}
+	defer sub.Stop()
+
+	if _, err = sub.Value(); !isForbidden(err) {
+		t.Errorf("Value() error = %v, want 403", err)
+	}
+	if err := (Conf{Token: "a", TokenFile: "b"}).Validate(); err == nil {
+		t.Error("expected error when both token and token file are set")
+	}
+}
@@ -0,0 +1,140 @@
+package consul
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type (
+	// ChangeEvent describes a change of the watched configuration.
+	// Old and New are the parsed settings, keys are lower-cased like viper does,
+	// and must be treated as read-only since they are shared by all listeners.
+	ChangeEvent struct {
+		Old map[string]any
+		New map[string]any
+		// Changed holds the sorted dotted paths of the added, removed or modified values.
+		Changed []string
+		// ModifyIndex is the highest ModifyIndex of the watched documents.
+		ModifyIndex uint64
+	}
+
+	// ChangeListener is called with the details of a configuration change.
+	ChangeListener func(event ChangeEvent)
+
+	changeListener struct {
+		listener ChangeListener
+		paths    [][]string
+	}
+)
+
+// Matches reports whether any of the changed paths matches pattern.
+// A pattern is a dotted path where * matches a single segment, e.g. redis.* or *.host;
+// it matches changes of the path itself, of its children and of its parents.
+func (e ChangeEvent) Matches(pattern string) bool {
+	return matchAny(e.Changed, [][]string{splitPath(pattern)})
+}
+
+func newChangeListener(listener ChangeListener, patterns []string) changeListener {
+	paths := make([][]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		paths = append(paths, splitPath(pattern))
+	}
+
+	return changeListener{
+		listener: listener,
+		paths:    paths,
+	}
+}
+
+// wants reports whether the listener subscribes to any of the changed paths.
+func (l changeListener) wants(changed []string) bool {
+	if len(l.paths) == 0 {
+		return true
+	}
+
+	return matchAny(changed, l.paths)
+}
+
+func matchAny(changed []string, patterns [][]string) bool {
+	for _, path := range changed {
+		segments := strings.Split(path, ".")
+		for _, pattern := range patterns {
+			if matchPath(pattern, segments) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchPath compares the common leading segments, so a pattern matches
+// its descendants (redis matches redis.host) and its ancestors
+// (redis.host matches redis when the whole section was replaced).
+func matchPath(pattern, segments []string) bool {
+	// a trailing * only widens the pattern to the children, which already match
+	if n := len(pattern); n > 0 && pattern[n-1] == "*" {
+		pattern = pattern[:n-1]
+	}
+
+	n := min(len(pattern), len(segments))
+	for i := 0; i < n; i++ {
+		if pattern[i] != "*" && pattern[i] != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func splitPath(pattern string) []string {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if len(pattern) == 0 {
+		return nil
+	}
+
+	return strings.Split(pattern, ".")
+}
+
+// diffSettings returns the sorted dotted paths that differ between old and new.
+// Nested maps are compared key by key, any other value as a whole.
+func diffSettings(old, new map[string]any) []string {
+	var changed []string
+	diffInto(&changed, "", old, new)
+	sort.Strings(changed)
+	return changed
+}
+
+func diffInto(changed *[]string, prefix string, old, new map[string]any) {
+	for key, oldVal := range old {
+		path := joinPath(prefix, key)
+		newVal, ok := new[key]
+		if !ok {
+			*changed = append(*changed, path)
+			continue
+		}
+
+		oldMap, oldOk := oldVal.(map[string]any)
+		newMap, newOk := newVal.(map[string]any)
+		if oldOk && newOk {
+			diffInto(changed, path, oldMap, newMap)
+		} else if !reflect.DeepEqual(oldVal, newVal) {
+			*changed = append(*changed, path)
+		}
+	}
+
+	for key := range new {
+		if _, ok := old[key]; !ok {
+			*changed = append(*changed, joinPath(prefix, key))
+		}
+	}
+}
+
+func joinPath(prefix, key string) string {
+	if len(prefix) == 0 {
+		return key
+	}
+
+	return prefix + "." + key
+}
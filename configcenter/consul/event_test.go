@@ -0,0 +1,80 @@
+package consul
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSettings(t *testing.T) {
+	old := map[string]any{
+		"name": "order",
+		"redis": map[string]any{
+			"host": "127.0.0.1:6379",
+			"type": "node",
+		},
+		"hosts":   []any{"a", "b"},
+		"removed": true,
+	}
+	new := map[string]any{
+		"name": "order",
+		"redis": map[string]any{
+			"host": "redis:6379",
+			"type": "node",
+		},
+		"hosts": []any{"a", "c"},
+		"mysql": map[string]any{
+			"dsn": "root@tcp(mysql)/order",
+		},
+	}
+
+	got := diffSettings(old, new)
+	want := []string{"hosts", "mysql", "redis.host", "removed"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSettings() = %v, want %v", got, want)
+	}
+
+	if got := diffSettings(old, old); len(got) != 0 {
+		t.Errorf("diffSettings() of equal settings = %v, want none", got)
+	}
+	if got := diffSettings(nil, map[string]any{"name": "order"}); !reflect.DeepEqual(got, []string{"name"}) {
+		t.Errorf("diffSettings() from nil = %v, want [name]", got)
+	}
+}
+
+func TestChangeEvent_Matches(t *testing.T) {
+	event := ChangeEvent{Changed: []string{"mysql", "redis.host"}}
+
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"", true},
+		{"*", true},
+		{"Redis.*", true},
+		{"redis", true},
+		{"redis.host", true},
+		{"redis.port", false},
+		{"*.host", true},
+		{"mysql.dsn", true},
+		{"log.*", false},
+	}
+	for _, tt := range tests {
+		if got := event.Matches(tt.pattern); got != tt.want {
+			t.Errorf("Matches(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestChangeListener_Wants(t *testing.T) {
+	changed := []string{"redis.host"}
+
+	if !newChangeListener(nil, nil).wants(changed) {
+		t.Error("listener without paths should want every change")
+	}
+	if !newChangeListener(nil, []string{"log.*", "redis.*"}).wants(changed) {
+		t.Error("listener should want redis.host")
+	}
+	if newChangeListener(nil, []string{"log.*"}).wants(changed) {
+		t.Error("listener should not want redis.host")
+	}
+}
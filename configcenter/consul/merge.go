@@ -0,0 +1,90 @@
+package consul
+
+import (
+	"strconv"
+	"strings"
+
+	consulApi "github.com/hashicorp/consul/api"
+)
+
+// commonPrefix returns the longest common prefix of keys, which is the
+// narrowest KV subtree a single blocking list query can cover them with.
+func commonPrefix(keys []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+
+	prefix := keys[0]
+	for _, key := range keys[1:] {
+		for !strings.HasPrefix(key, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	return prefix
+}
+
+// pickKeys returns the pairs of keys in the order of keys, missing keys are skipped.
+func pickKeys(pairs consulApi.KVPairs, keys []string) consulApi.KVPairs {
+	byKey := make(map[string]*consulApi.KVPair, len(pairs))
+	for _, pair := range pairs {
+		byKey[pair.Key] = pair
+	}
+
+	var picked consulApi.KVPairs
+	for _, key := range keys {
+		if pair, ok := byKey[key]; ok {
+			picked = append(picked, pair)
+		}
+	}
+
+	return picked
+}
+
+// documents drops the folder entries of a prefix listing.
+func documents(pairs consulApi.KVPairs) consulApi.KVPairs {
+	var docs consulApi.KVPairs
+	for _, pair := range pairs {
+		if strings.HasSuffix(pair.Key, "/") && len(pair.Value) == 0 {
+			continue
+		}
+		docs = append(docs, pair)
+	}
+
+	return docs
+}
+
+// version identifies a set of pairs by their keys and modify indexes,
+// so that changes to unrelated keys under a shared prefix are ignored.
+func version(pairs consulApi.KVPairs) string {
+	var sb strings.Builder
+	for _, pair := range pairs {
+		sb.WriteString(pair.Key)
+		sb.WriteByte('@')
+		sb.WriteString(strconv.FormatUint(pair.ModifyIndex, 10))
+		sb.WriteByte(';')
+	}
+
+	return sb.String()
+}
+
+// MergeSettings deep-merges src into dst, values in src win. Nested maps are
+// merged recursively, any other value (including slices) is replaced. The chain
+// subscriber in configcenter/chain merges its sources with it as well.
+func MergeSettings(dst, src map[string]any) map[string]any {
+	if dst == nil {
+		dst = make(map[string]any, len(src))
+	}
+
+	for key, val := range src {
+		srcMap, srcOk := val.(map[string]any)
+		dstMap, dstOk := dst[key].(map[string]any)
+		if srcOk && dstOk {
+			dst[key] = MergeSettings(dstMap, srcMap)
+		} else {
+			dst[key] = val
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,50 @@
+package consul
+
+import "github.com/zeromicro/go-zero/core/metric"
+
+const metricsNamespace = "configcenter_consul"
+
+var (
+	metricSnapshotFallbackTotal = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "snapshot",
+		Name:      "fallback_total",
+		Help:      "consul config center reads served from the local snapshot.",
+		Labels:    []string{"key"},
+	})
+	metricSnapshotStale = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "snapshot",
+		Name:      "stale",
+		Help:      "consul config center serves a stale local snapshot, 1 for yes and 0 for no.",
+		Labels:    []string{"key"},
+	})
+	metricWatchErrorsTotal = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "watch",
+		Name:      "errors_total",
+		Help:      "consul config center watch query errors.",
+		Labels:    []string{"key"},
+	})
+	metricWatchReloadsTotal = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "watch",
+		Name:      "reloads_total",
+		Help:      "consul config center changes picked up by the watch.",
+		Labels:    []string{"key"},
+	})
+	metricWatchLastSuccess = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "watch",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "consul config center unix time of the last successful watch query.",
+		Labels:    []string{"key"},
+	})
+	metricRejectedTotal = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "validation",
+		Name:      "rejected_total",
+		Help:      "consul config center revisions rejected by parsing or validation.",
+		Labels:    []string{"key"},
+	})
+)
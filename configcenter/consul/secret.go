@@ -0,0 +1,192 @@
+package consul
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	secretPrefix  = "ENC[AES256_GCM,"
+	secretSuffix  = "]"
+	secretKeySize = 32
+)
+
+var (
+	// ErrNoSecretKey is returned when a document holds encrypted values but no key is configured.
+	ErrNoSecretKey = errors.New("no secret key configured for encrypted values")
+	// ErrInvalidSecretKey is returned for a key that is not a base64 encoded 32-byte AES-256 key.
+	ErrInvalidSecretKey = errors.New("secret key must be a base64 encoded 32-byte key")
+	// ErrMalformedSecret is returned for an ENC[...] value that cannot be decrypted.
+	ErrMalformedSecret = errors.New("malformed encrypted value")
+)
+
+// GenerateSecretKey returns a random base64 encoded AES-256 key.
+func GenerateSecretKey() (string, error) {
+	key := make([]byte, secretKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseSecretKey decodes a base64 encoded AES-256 key, surrounding whitespace is ignored.
+func ParseSecretKey(text string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil || len(key) != secretKeySize {
+		return nil, ErrInvalidSecretKey
+	}
+
+	return key, nil
+}
+
+// EncryptValue encrypts plaintext with AES-256-GCM into an ENC[AES256_GCM,...] value.
+func EncryptValue(key []byte, plaintext string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed) + secretSuffix, nil
+}
+
+// DecryptValue decrypts an ENC[AES256_GCM,...] value produced by EncryptValue.
+func DecryptValue(key []byte, value string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	return openSecret(aead, value)
+}
+
+// IsEncrypted reports whether value is an ENC[AES256_GCM,...] value.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, secretPrefix) && strings.HasSuffix(value, secretSuffix)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != secretKeySize {
+		return nil, ErrInvalidSecretKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func openSecret(aead cipher.AEAD, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", ErrMalformedSecret
+	}
+
+	encoded := strings.TrimSuffix(strings.TrimPrefix(value, secretPrefix), secretSuffix)
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return "", ErrMalformedSecret
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedSecret, err)
+	}
+
+	return string(plaintext), nil
+}
+
+// loadSecretKey reads the key from file or from the environment variable env,
+// it returns nil without error if neither is configured.
+func loadSecretKey(file, env string) ([]byte, error) {
+	switch {
+	case len(file) > 0:
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read secret key file: %w", err)
+		}
+		return ParseSecretKey(string(content))
+	case len(env) > 0:
+		text, ok := os.LookupEnv(env)
+		if !ok {
+			return nil, fmt.Errorf("secret key env %s is not set", env)
+		}
+		return ParseSecretKey(text)
+	default:
+		return nil, nil
+	}
+}
+
+// decryptSettings returns a copy of settings with every encrypted string decrypted.
+// aead may be nil, then any encrypted value fails with ErrNoSecretKey.
+func decryptSettings(aead cipher.AEAD, settings map[string]any) (map[string]any, error) {
+	val, err := decryptValue(aead, "", settings)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, _ := val.(map[string]any)
+	return decrypted, nil
+}
+
+func decryptValue(aead cipher.AEAD, path string, val any) (any, error) {
+	switch v := val.(type) {
+	case map[string]any:
+		// sorted, so that the first failing path reported is stable
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		out := make(map[string]any, len(v))
+		for _, key := range keys {
+			item := v[key]
+			decrypted, err := decryptValue(aead, joinPath(path, key), item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = decrypted
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			decrypted, err := decryptValue(aead, fmt.Sprintf("%s[%d]", path, i), item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = decrypted
+		}
+		return out, nil
+	case string:
+		if !IsEncrypted(v) {
+			return v, nil
+		}
+		if aead == nil {
+			return nil, fmt.Errorf("decrypt %s: %w", path, ErrNoSecretKey)
+		}
+		plaintext, err := openSecret(aead, v)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt %s: %w", path, err)
+		}
+		return plaintext, nil
+	default:
+		return val, nil
+	}
+}
@@ -0,0 +1,170 @@
+package consul
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestKey(t *testing.T) (string, []byte) {
+	t.Helper()
+
+	text, err := GenerateSecretKey()
+	if err != nil {
+		t.Fatalf("GenerateSecretKey error: %v", err)
+	}
+	key, err := ParseSecretKey(text)
+	if err != nil {
+		t.Fatalf("ParseSecretKey error: %v", err)
+	}
+	return text, key
+}
+
+func TestEncryptDecryptValue(t *testing.T) {
+	_, key := newTestKey(t)
+
+	encrypted, err := EncryptValue(key, "p@ssw0rd")
+	if err != nil {
+		t.Fatalf("EncryptValue error: %v", err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "p@ssw0rd") {
+		t.Fatalf("EncryptValue() = %q", encrypted)
+	}
+
+	again, _ := EncryptValue(key, "p@ssw0rd")
+	if again == encrypted {
+		t.Error("EncryptValue should use a random nonce")
+	}
+
+	plaintext, err := DecryptValue(key, encrypted)
+	if err != nil {
+		t.Fatalf("DecryptValue error: %v", err)
+	}
+	if plaintext != "p@ssw0rd" {
+		t.Errorf("DecryptValue() = %q, want p@ssw0rd", plaintext)
+	}
+}
+
+func TestDecryptValue_Errors(t *testing.T) {
+	_, key := newTestKey(t)
+	_, otherKey := newTestKey(t)
+	encrypted, _ := EncryptValue(key, "p@ssw0rd")
+	sealed, _ := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(encrypted, secretPrefix), secretSuffix))
+	sealed[len(sealed)-1] ^= 0xff
+	tampered := secretPrefix + base64.StdEncoding.EncodeToString(sealed) + secretSuffix
+
+	tests := []struct {
+		name  string
+		key   []byte
+		value string
+		want  error
+	}{
+		{"wrong key", otherKey, encrypted, ErrMalformedSecret},
+		{"tampered", key, tampered, ErrMalformedSecret},
+		{"not encrypted", key, "p@ssw0rd", ErrMalformedSecret},
+		{"bad base64", key, "ENC[AES256_GCM,!!!]", ErrMalformedSecret},
+		{"too short", key, "ENC[AES256_GCM,AAAA]", ErrMalformedSecret},
+		{"short key", key[:16], encrypted, ErrInvalidSecretKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecryptValue(tt.key, tt.value); !errors.Is(err, tt.want) {
+				t.Errorf("DecryptValue() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSecretKey(t *testing.T) {
+	text, _ := newTestKey(t)
+
+	if _, err := ParseSecretKey(" " + text + "\n"); err != nil {
+		t.Errorf("ParseSecretKey with whitespace error: %v", err)
+	}
+	if _, err := ParseSecretKey("c2hvcnQ="); !errors.Is(err, ErrInvalidSecretKey) {
+		t.Errorf("ParseSecretKey(short) error = %v", err)
+	}
+	if _, err := ParseSecretKey("not base64!"); !errors.Is(err, ErrInvalidSecretKey) {
+		t.Errorf("ParseSecretKey(invalid) error = %v", err)
+	}
+}
+
+func TestLoadSecretKey(t *testing.T) {
+	text, key := newTestKey(t)
+	file := filepath.Join(t.TempDir(), "secret.key")
+	if err := os.WriteFile(file, []byte(text+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONSUL_TEST_SECRET_KEY", text)
+
+	got, err := loadSecretKey(file, "")
+	if err != nil || string(got) != string(key) {
+		t.Errorf("loadSecretKey(file) = %v, %v", got, err)
+	}
+	got, err = loadSecretKey("", "CONSUL_TEST_SECRET_KEY")
+	if err != nil || string(got) != string(key) {
+		t.Errorf("loadSecretKey(env) = %v, %v", got, err)
+	}
+	if got, err = loadSecretKey("", ""); got != nil || err != nil {
+		t.Errorf("loadSecretKey() = %v, %v, want nil", got, err)
+	}
+	if _, err = loadSecretKey(filepath.Join(t.TempDir(), "missing"), ""); err == nil {
+		t.Error("expected error for a missing key file")
+	}
+	if _, err = loadSecretKey("", "CONSUL_TEST_SECRET_KEY_MISSING"); err == nil {
+		t.Error("expected error for a missing env var")
+	}
+}
+
+func TestDecryptSettings(t *testing.T) {
+	_, key := newTestKey(t)
+	aead, err := newAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, _ := EncryptValue(key, "p@ssw0rd")
+	token, _ := EncryptValue(key, "t0ken")
+
+	settings := map[string]any{
+		"name": "order",
+		"mysql": map[string]any{
+			"password": password,
+		},
+		"tokens": []any{token, "plain"},
+		"port":   8080,
+	}
+
+	got, err := decryptSettings(aead, settings)
+	if err != nil {
+		t.Fatalf("decryptSettings error: %v", err)
+	}
+	if got["mysql"].(map[string]any)["password"] != "p@ssw0rd" {
+		t.Errorf("mysql.password = %v", got["mysql"])
+	}
+	if tokens := got["tokens"].([]any); tokens[0] != "t0ken" || tokens[1] != "plain" {
+		t.Errorf("tokens = %v", tokens)
+	}
+	if got["name"] != "order" || got["port"] != 8080 {
+		t.Errorf("plain values changed: %v", got)
+	}
+	if settings["mysql"].(map[string]any)["password"] != password {
+		t.Error("decryptSettings modified its input")
+	}
+
+	_, err = decryptSettings(nil, settings)
+	if !errors.Is(err, ErrNoSecretKey) || !strings.Contains(err.Error(), "mysql.password") {
+		t.Errorf("decryptSettings without key error = %v", err)
+	}
+
+	if _, err = decryptSettings(nil, map[string]any{"plain": "value"}); err != nil {
+		t.Errorf("decryptSettings of plain settings without key error = %v", err)
+	}
+
+	settings["mysql"].(map[string]any)["password"] = "ENC[AES256_GCM,broken]"
+	if _, err = decryptSettings(aead, settings); !errors.Is(err, ErrMalformedSecret) {
+		t.Errorf("decryptSettings of a broken value error = %v", err)
+	}
+}
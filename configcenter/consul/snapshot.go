@@ -0,0 +1,49 @@
+package consul
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// readSnapshot returns the content saved at path.
+func readSnapshot(path string) (string, bool) {
+	if len(path) == 0 {
+		return "", false
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	return string(content), true
+}
+
+// writeSnapshot saves content at path through a temporary file and a rename,
+// so a crash never leaves a partial snapshot behind.
+func writeSnapshot(path string, content string) error {
+	if len(path) == 0 {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.WriteString(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,93 @@
+package consul
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	consulApi "github.com/hashicorp/consul/api"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// tokenSource provides the ACL token, re-reading the token file when it's rotated,
+// e.g. by Vault Agent or a Kubernetes secret volume.
+type tokenSource struct {
+	static  string
+	file    string
+	lock    sync.Mutex
+	token   string
+	modTime time.Time
+}
+
+func newTokenSource(static, file string) (*tokenSource, error) {
+	t := &tokenSource{
+		static: static,
+		file:   file,
+	}
+	if len(file) == 0 {
+		return t, nil
+	}
+
+	if err := t.reload(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Token returns the current token, re-reading the file if it was modified since the last read.
+func (t *tokenSource) Token() string {
+	if len(t.file) == 0 {
+		return t.static
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if info, err := os.Stat(t.file); err == nil && !info.ModTime().Equal(t.modTime) {
+		if err = t.readLocked(); err != nil {
+			logx.Errorf("read consul token file %s failed, keeping the current token: %v", t.file, err)
+		}
+	}
+
+	return t.token
+}
+
+// reload re-reads the token file regardless of its modification time.
+func (t *tokenSource) reload() error {
+	if len(t.file) == 0 {
+		return nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.readLocked()
+}
+
+func (t *tokenSource) readLocked() error {
+	info, err := os.Stat(t.file)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(t.file)
+	if err != nil {
+		return err
+	}
+
+	token := strings.TrimSpace(string(content))
+	if len(token) == 0 {
+		return errors.New("empty consul token file " + t.file)
+	}
+
+	t.token = token
+	t.modTime = info.ModTime()
+	return nil
+}
+
+// isForbidden reports whether err is a 403 returned by Consul, usually for a revoked token.
+func isForbidden(err error) bool {
+	var statusErr consulApi.StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusForbidden
+}
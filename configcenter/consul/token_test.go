@@ -0,0 +1,100 @@
+package consul
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	consulApi "github.com/hashicorp/consul/api"
+)
+
+func TestTokenSource_Static(t *testing.T) {
+	tokens, err := newTokenSource("static", "")
+	if err != nil {
+		t.Fatalf("newTokenSource error: %v", err)
+	}
+	if got := tokens.Token(); got != "static" {
+		t.Errorf("Token() = %q, want static", got)
+	}
+	if err := tokens.reload(); err != nil {
+		t.Errorf("reload() error: %v", err)
+	}
+	if got := tokens.Token(); got != "static" {
+		t.Errorf("Token() after reload = %q, want static", got)
+	}
+}
+
+func TestTokenSource_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := newTokenSource("", file)
+	if err != nil {
+		t.Fatalf("newTokenSource error: %v", err)
+	}
+	if got := tokens.Token(); got != "old" {
+		t.Errorf("Token() = %q, want old", got)
+	}
+
+	// a rotation with a new modification time is picked up by Token
+	if err := os.WriteFile(file, []byte("new"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := tokens.Token(); got != "new" {
+		t.Errorf("Token() = %q, want new", got)
+	}
+
+	// a rotation keeping the modification time needs a forced reload
+	if err := os.WriteFile(file, []byte("newer"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := tokens.Token(); got != "new" {
+		t.Errorf("Token() = %q, want the cached new", got)
+	}
+	if err := tokens.reload(); err != nil {
+		t.Errorf("reload() error: %v", err)
+	}
+	if got := tokens.Token(); got != "newer" {
+		t.Errorf("Token() = %q, want newer", got)
+	}
+
+	// a broken file keeps the current token
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, later.Add(time.Minute), later.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got := tokens.Token(); got != "newer" {
+		t.Errorf("Token() = %q, want newer kept", got)
+	}
+}
+
+func TestTokenSource_MissingFile(t *testing.T) {
+	if _, err := newTokenSource("", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("expected error for a missing token file")
+	}
+}
+
+func TestIsForbidden(t *testing.T) {
+	if !isForbidden(consulApi.StatusError{Code: 403, Body: "ACL not found"}) {
+		t.Error("isForbidden(403) = false")
+	}
+	if isForbidden(consulApi.StatusError{Code: 500}) {
+		t.Error("isForbidden(500) = true")
+	}
+	if isForbidden(errors.New("403")) || isForbidden(nil) {
+		t.Error("isForbidden of a plain error = true")
+	}
+}
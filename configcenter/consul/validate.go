@@ -0,0 +1,54 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/zeromicro/go-zero/core/conf"
+)
+
+// ErrInvalidConfig is returned for a revision rejected by the validation pipeline.
+var ErrInvalidConfig = errors.New("invalid consul config")
+
+// Validator checks the parsed settings of a revision before it is accepted.
+// The settings are shared and must not be modified.
+type Validator func(settings map[string]any) error
+
+// WithValidator adds a validator, validators run in the order they are added.
+func WithValidator(validator Validator) Option {
+	return func(s *ConsulSubscriber) {
+		s.validators = append(s.validators, validator)
+	}
+}
+
+// WithTarget validates every revision by loading it into a new value of target's type,
+// applying the go-zero struct tags (optional, default, options, range) and the
+// Validate method of the type if it implements one. target is a struct or a pointer to one.
+func WithTarget(target any) Option {
+	typ := reflect.TypeOf(target)
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	return func(s *ConsulSubscriber) {
+		s.target = typ
+	}
+}
+
+// validate runs the validation pipeline on the parsed settings and their JSON form.
+func (s *ConsulSubscriber) validate(settings map[string]any, content []byte) error {
+	if s.target != nil {
+		if err := conf.LoadFromJsonBytes(content, reflect.New(s.target).Interface()); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+	}
+
+	for _, validator := range s.validators {
+		if err := validator(settings); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+	}
+
+	return nil
+}
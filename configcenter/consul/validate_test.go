@@ -0,0 +1,80 @@
+package consul
+
+import (
+	"errors"
+	"testing"
+)
+
+type validateTarget struct {
+	Name  string
+	Mode  string `json:",default=dev,options=dev|test|prod"`
+	Port  int    `json:",range=[1:65535]"`
+	Redis struct {
+		Host string
+	} `json:",optional"`
+}
+
+func (t *validateTarget) Validate() error {
+	if t.Name == "forbidden" {
+		return errors.New("forbidden name")
+	}
+	return nil
+}
+
+func TestValidate_Target(t *testing.T) {
+	s := &ConsulSubscriber{}
+	WithTarget(&validateTarget{})(s)
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"valid", `{"name":"order","port":8080}`, false},
+		{"lower-cased keys", `{"name":"order","mode":"prod","port":8080,"redis":{"host":"redis:6379"}}`, false},
+		{"missing required", `{"port":8080}`, true},
+		{"bad option", `{"name":"order","mode":"stage","port":8080}`, true},
+		{"out of range", `{"name":"order","port":70000}`, true},
+		{"wrong type", `{"name":"order","port":"http"}`, true},
+		{"validate method", `{"name":"forbidden","port":8080}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.validate(nil, []byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("validate() error = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestValidate_Validators(t *testing.T) {
+	var calls []string
+	s := &ConsulSubscriber{}
+	WithValidator(func(settings map[string]any) error {
+		calls = append(calls, "first")
+		return nil
+	})(s)
+	WithValidator(func(settings map[string]any) error {
+		calls = append(calls, "second")
+		if settings["port"] == nil {
+			return errors.New("port is required")
+		}
+		return nil
+	})(s)
+
+	if err := s.validate(map[string]any{"port": 8080}, nil); err != nil {
+		t.Fatalf("validate() error: %v", err)
+	}
+	if len(calls) != 2 || calls[0] != "first" || calls[1] != "second" {
+		t.Errorf("validators called %v, want in order", calls)
+	}
+
+	err := s.validate(map[string]any{}, nil)
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("validate() error = %v, want ErrInvalidConfig", err)
+	}
+}
@@ -0,0 +1,249 @@
+package nacos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	requestTimeout = 5 * time.Second
+	// listenerGrace is added to the long polling timeout, nacos may hold the request slightly longer.
+	listenerGrace = 10 * time.Second
+	// tokenRefreshRatio refreshes the access token once this share of its ttl has passed.
+	tokenRefreshRatio = 0.9
+	// defaultTokenTtl is the nacos default token ttl in seconds, used if the login response has none.
+	defaultTokenTtl = 18000
+
+	wordSeparator = "\x02"
+	lineSeparator = "\x01"
+)
+
+var (
+	errConfigNotFound = errors.New("nacos config not found")
+	errForbidden      = errors.New("nacos access forbidden")
+)
+
+type (
+	// nacosClient talks to the nacos open api v1.
+	nacosClient struct {
+		httpClient *http.Client
+		baseURL    string
+		username   string
+		password   string
+		timeout    time.Duration
+
+		lock        sync.Mutex
+		token       string
+		tokenExpire time.Time
+	}
+
+	// configKey identifies one config on nacos.
+	configKey struct {
+		dataId string
+		group  string
+		tenant string
+	}
+
+	loginResponse struct {
+		AccessToken string `json:"accessToken"`
+		TokenTtl    int64  `json:"tokenTtl"`
+	}
+)
+
+func newNacosClient(c Conf) *nacosClient {
+	return &nacosClient{
+		httpClient: &http.Client{},
+		baseURL:    fmt.Sprintf("%s://%s/%s", c.Scheme, c.Host, strings.Trim(c.ContextPath, "/")),
+		username:   c.Username,
+		password:   c.Password,
+		timeout:    time.Duration(c.Timeout) * time.Millisecond,
+	}
+}
+
+// getConfig returns the content of key, errConfigNotFound if it does not exist.
+func (c *nacosClient) getConfig(ctx context.Context, key configKey) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	body, err := c.do(ctx, func(token string) (*http.Request, error) {
+		query := key.values()
+		setToken(query, token)
+		return http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/v1/cs/configs?"+query.Encode(), nil)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+// listen long polls nacos until key no longer matches md5 or the timeout passes,
+// it reports whether the config changed.
+func (c *nacosClient) listen(ctx context.Context, key configKey, md5 string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout+listenerGrace)
+	defer cancel()
+
+	line := key.dataId + wordSeparator + key.group + wordSeparator + md5
+	if len(key.tenant) > 0 {
+		line += wordSeparator + key.tenant
+	}
+	form := url.Values{"Listening-Configs": {line + lineSeparator}}
+
+	body, err := c.do(ctx, func(token string) (*http.Request, error) {
+		query := url.Values{}
+		setToken(query, token)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+			c.baseURL+"/v1/cs/configs/listener?"+query.Encode(), strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Long-Pulling-Timeout", fmt.Sprint(c.timeout.Milliseconds()))
+		return req, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return len(strings.TrimSpace(string(body))) > 0, nil
+}
+
+// do sends the request built by newRequest, logging in again once if nacos rejects the access token.
+func (c *nacosClient) do(ctx context.Context, newRequest func(token string) (*http.Request, error)) ([]byte, error) {
+	body, err := c.doOnce(ctx, newRequest)
+	if errors.Is(err, errForbidden) && len(c.username) > 0 {
+		c.resetToken()
+		body, err = c.doOnce(ctx, newRequest)
+	}
+
+	return body, err
+}
+
+func (c *nacosClient) doOnce(ctx context.Context, newRequest func(token string) (*http.Request, error)) ([]byte, error) {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newRequest(token)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, errConfigNotFound
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return nil, fmt.Errorf("%w: %s", errForbidden, strings.TrimSpace(string(body)))
+	default:
+		return nil, fmt.Errorf("nacos responded %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+}
+
+// accessToken returns a valid access token, logging in when it is missing or about to expire.
+// It returns an empty token if auth is disabled.
+func (c *nacosClient) accessToken(ctx context.Context) (string, error) {
+	if len(c.username) == 0 {
+		return "", nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.token) > 0 && time.Now().Before(c.tokenExpire) {
+		return c.token, nil
+	}
+
+	form := url.Values{"username": {c.username}, "password": {c.password}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/auth/login",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("nacos login failed, status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var login loginResponse
+	if err = json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		return "", fmt.Errorf("nacos login failed: %w", err)
+	}
+	if len(login.AccessToken) == 0 {
+		return "", errors.New("nacos login failed: empty access token")
+	}
+
+	tokenTtl := login.TokenTtl
+	if tokenTtl <= 0 {
+		tokenTtl = defaultTokenTtl
+	}
+
+	c.token = login.AccessToken
+	ttl := time.Duration(float64(tokenTtl)*tokenRefreshRatio) * time.Second
+	c.tokenExpire = time.Now().Add(ttl)
+	return c.token, nil
+}
+
+func (c *nacosClient) resetToken() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.token = ""
+}
+
+func (k configKey) values() url.Values {
+	values := url.Values{
+		"dataId": {k.dataId},
+		"group":  {k.group},
+	}
+	if len(k.tenant) > 0 {
+		values.Set("tenant", k.tenant)
+	}
+
+	return values
+}
+
+func (k configKey) String() string {
+	tenant := k.tenant
+	if len(tenant) == 0 {
+		tenant = publicNamespace
+	}
+
+	return tenant + "/" + k.group + "/" + k.dataId
+}
+
+func setToken(query url.Values, token string) {
+	if len(token) > 0 {
+		query.Set("accessToken", token)
+	}
+}
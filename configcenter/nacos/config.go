@@ -0,0 +1,73 @@
+package nacos
+
+import "errors"
+
+const (
+	defaultScheme      = "http"
+	defaultContextPath = "/nacos"
+	defaultGroup       = "DEFAULT_GROUP"
+	defaultTimeout     = 30000
+	publicNamespace    = "public"
+
+	typeYaml       = "yaml"
+	typeJson       = "json"
+	typeProperties = "properties"
+)
+
+// Conf is the config item with the given DataId on nacos.
+// Host is the nacos address. example: "127.0.0.1:8848"
+// ContextPath is the context path of the nacos server. example: "/nacos"
+// Namespace is the namespace id, empty means the public namespace. example: "dev"
+// Group is the config group. example: "DEFAULT_GROUP"
+// DataId is the config data id. example: "DemoA.api"
+// Type is the config type. example: "yaml", "json", "properties"
+// Username and Password enable nacos auth.
+// SnapshotDir keeps the last fetched config, served when nacos is unreachable. example: "/data/nacos/snapshot"
+// Timeout is the long polling timeout in milliseconds. example: 30000
+type Conf struct {
+	Host        string `json:",optional"`
+	Scheme      string `json:",default=http,options=http|https"`
+	ContextPath string `json:",default=/nacos"`
+	Namespace   string `json:",optional"`
+	Group       string `json:",default=DEFAULT_GROUP"`
+	DataId      string `json:",optional"`
+	Type        string `json:",default=yaml,options=yaml|json|properties"`
+	Username    string `json:",optional"`
+	Password    string `json:",optional"`
+	SnapshotDir string `json:",optional"`
+	Timeout     int64  `json:",default=30000"`
+}
+
+// Validate validates c.
+func (c *Conf) Validate() error {
+	if len(c.Host) == 0 {
+		return errors.New("empty nacos host")
+	}
+	if len(c.DataId) == 0 {
+		return errors.New("empty nacos data id")
+	}
+
+	if len(c.Scheme) == 0 {
+		c.Scheme = defaultScheme
+	}
+	if len(c.ContextPath) == 0 {
+		c.ContextPath = defaultContextPath
+	}
+	if len(c.Group) == 0 {
+		c.Group = defaultGroup
+	}
+	if len(c.Type) == 0 {
+		c.Type = typeYaml
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+
+	switch c.Type {
+	case typeYaml, typeJson, typeProperties:
+	default:
+		return errors.New("unknown nacos config type: " + c.Type)
+	}
+
+	return nil
+}
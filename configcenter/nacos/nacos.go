@@ -0,0 +1,259 @@
+package nacos
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/spf13/viper"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const retryInterval = time.Second
+
+type (
+	// NacosSubscriber is a subscriber that subscribes to Nacos.
+	NacosSubscriber struct {
+		client    *nacosClient
+		key       configKey
+		snapshot  string
+		DataId    string
+		Group     string
+		Namespace string
+		Type      string
+		listeners []func()
+		lock      sync.Mutex
+		ctx       context.Context
+		cancel    context.CancelFunc
+		stopOnce  sync.Once
+	}
+
+	// NacosConf is the configuration for Nacos.
+	NacosConf Conf
+)
+
+// MustNewNacosSubscriber returns a Nacos Subscriber, exits on errors.
+func MustNewNacosSubscriber(conf NacosConf) *NacosSubscriber {
+	s, err := NewNacosSubscriber(conf)
+	logx.Must(err)
+	return s
+}
+
+// NewNacosSubscriber returns a Nacos Subscriber.
+func NewNacosSubscriber(conf NacosConf) (*NacosSubscriber, error) {
+	c := Conf(conf)
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	key := configKey{
+		dataId: c.DataId,
+		group:  c.Group,
+		tenant: c.Namespace,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	subscriber := &NacosSubscriber{
+		client:    newNacosClient(c),
+		key:       key,
+		snapshot:  snapshotPath(c.SnapshotDir, key),
+		DataId:    c.DataId,
+		Group:     c.Group,
+		Namespace: c.Namespace,
+		Type:      c.Type,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	go subscriber.watch()
+	return subscriber, nil
+}
+
+// AddListener adds a listener to the subscriber.
+func (s *NacosSubscriber) AddListener(listener func()) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.listeners = append(s.listeners, listener)
+	return nil
+}
+
+// Value returns the current value from Nacos, or from the local snapshot if Nacos is unreachable.
+func (s *NacosSubscriber) Value() (string, error) {
+	content, err := s.content()
+	if err != nil {
+		return "", err
+	}
+	if len(content) == 0 {
+		return "", nil
+	}
+
+	return parse(content, s.Type)
+}
+
+// content fetches the raw config, an empty string if it does not exist.
+func (s *NacosSubscriber) content() (string, error) {
+	content, err := s.client.getConfig(s.ctx, s.key)
+	switch {
+	case errors.Is(err, errConfigNotFound):
+		return "", nil
+	case err != nil:
+		snapshot, ok := readSnapshot(s.snapshot)
+		if !ok {
+			return "", err
+		}
+
+		logx.Errorf("fetch nacos config %s failed, using local snapshot %s: %v", s.key, s.snapshot, err)
+		return snapshot, nil
+	default:
+		s.saveSnapshot(content)
+		return content, nil
+	}
+}
+
+// watch long polls Nacos for changes and triggers listeners.
+func (s *NacosSubscriber) watch() {
+	var lastMd5 string
+	if content, err := s.client.getConfig(s.ctx, s.key); err == nil {
+		lastMd5 = md5Hex(content)
+	} else if snapshot, ok := readSnapshot(s.snapshot); ok && !errors.Is(err, errConfigNotFound) {
+		lastMd5 = md5Hex(snapshot)
+	}
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		default:
+		}
+
+		changed, err := s.client.listen(s.ctx, s.key, lastMd5)
+		if err != nil {
+			s.retryLater(err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		content, err := s.client.getConfig(s.ctx, s.key)
+		if errors.Is(err, errConfigNotFound) {
+			content, err = "", nil
+		}
+		if err != nil {
+			s.retryLater(err)
+			continue
+		}
+
+		if md5 := md5Hex(content); md5 != lastMd5 {
+			lastMd5 = md5
+			s.saveSnapshot(content)
+			s.notifyListeners()
+		}
+	}
+}
+
+// retryLater logs err and waits before the next attempt, unless the subscriber is stopped.
+func (s *NacosSubscriber) retryLater(err error) {
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	logx.Errorf("watch nacos config %s failed: %v", s.key, err)
+	select {
+	case <-s.ctx.Done():
+	case <-time.After(retryInterval):
+	}
+}
+
+func (s *NacosSubscriber) saveSnapshot(content string) {
+	if err := writeSnapshot(s.snapshot, content); err != nil {
+		logx.Errorf("save nacos snapshot %s failed: %v", s.snapshot, err)
+	}
+}
+
+// notifyListeners calls all registered listeners.
+func (s *NacosSubscriber) notifyListeners() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, listener := range s.listeners {
+		listener()
+	}
+}
+
+// Stop stops the watch process, cancelling the in-flight long polling request.
+func (s *NacosSubscriber) Stop() {
+	s.stopOnce.Do(s.cancel)
+}
+
+// parse converts content of the given type into a JSON string.
+func parse(content, typ string) (string, error) {
+	v := viper.New()
+	if typ == typeProperties {
+		// viper no longer ships a properties decoder, dotted keys are nested by Set
+		props, err := properties.LoadString(content)
+		if err != nil {
+			return "", err
+		}
+		for _, key := range props.Keys() {
+			v.Set(key, propertyValue(props.GetString(key, "")))
+		}
+	} else {
+		v.SetConfigType(typ)
+		if err := v.ReadConfig(bytes.NewBufferString(content)); err != nil {
+			return "", err
+		}
+	}
+
+	marshal, err := json.Marshal(v.AllSettings())
+	if err != nil {
+		return "", err
+	}
+
+	return string(marshal), nil
+}
+
+// propertyValue infers the type of a properties value, which is always a string,
+// so that e.g. port=8080 loads into an int field. Numbers with a leading zero or
+// plus sign, such as 007, and integers overflowing int64 are kept as strings
+// since they wouldn't round-trip.
+func propertyValue(val string) any {
+	switch strings.ToLower(val) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	digits := strings.TrimPrefix(val, "-")
+	if strings.HasPrefix(val, "+") || len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return val
+	}
+	if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return i
+	}
+	// integers beyond int64, e.g. 20-digit ids, would lose digits as a float
+	if !strings.ContainsAny(val, ".eE") {
+		return val
+	}
+	if f, err := strconv.ParseFloat(val, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f
+	}
+
+	return val
+}
+
+func md5Hex(content string) string {
+	if len(content) == 0 {
+		return ""
+	}
+
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,549 @@
+package nacos
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/conf"
+)
+
+type mockNacosServer struct {
+	*httptest.Server
+	mu           sync.Mutex
+	configs      map[string]string
+	changed      chan struct{}
+	username     string
+	password     string
+	tokens       map[string]bool
+	tokenSeq     int
+	logins       int32
+	listenCount  int32
+	pollDuration time.Duration
+}
+
+func newMockNacosServer(t *testing.T) *mockNacosServer {
+	m := &mockNacosServer{
+		configs:      make(map[string]string),
+		changed:      make(chan struct{}),
+		tokens:       make(map[string]bool),
+		pollDuration: 200 * time.Millisecond,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nacos/v1/auth/login", m.handleLogin)
+	mux.HandleFunc("/nacos/v1/cs/configs", m.handleConfig)
+	mux.HandleFunc("/nacos/v1/cs/configs/listener", m.handleListener)
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockNacosServer) host() string {
+	return strings.TrimPrefix(m.URL, "http://")
+}
+
+func configID(dataId, group, tenant string) string {
+	return tenant + "/" + group + "/" + dataId
+}
+
+func (m *mockNacosServer) setConfig(dataId, group, tenant, content string) {
+	m.mu.Lock()
+	m.configs[configID(dataId, group, tenant)] = content
+	close(m.changed)
+	m.changed = make(chan struct{})
+	m.mu.Unlock()
+}
+
+func (m *mockNacosServer) enableAuth(username, password string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.username = username
+	m.password = password
+}
+
+// revokeTokens simulates expired or rotated access tokens.
+func (m *mockNacosServer) revokeTokens() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens = make(map[string]bool)
+}
+
+func (m *mockNacosServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&m.logins, 1)
+	_ = r.ParseForm()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r.PostForm.Get("username") != m.username || r.PostForm.Get("password") != m.password {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("unknown user!"))
+		return
+	}
+
+	m.tokenSeq++
+	token := "token-" + string(rune('a'+m.tokenSeq))
+	m.tokens[token] = true
+	_ = json.NewEncoder(w).Encode(map[string]any{"accessToken": token, "tokenTtl": 18000})
+}
+
+func (m *mockNacosServer) authorized(w http.ResponseWriter, r *http.Request) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.username) == 0 || m.tokens[r.URL.Query().Get("accessToken")] {
+		return true
+	}
+
+	w.WriteHeader(http.StatusForbidden)
+	_, _ = w.Write([]byte("token invalid!"))
+	return false
+}
+
+func (m *mockNacosServer) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if !m.authorized(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	m.mu.Lock()
+	content, ok := m.configs[configID(q.Get("dataId"), q.Get("group"), q.Get("tenant"))]
+	m.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("config data not exist"))
+		return
+	}
+
+	_, _ = w.Write([]byte(content))
+}
+
+func (m *mockNacosServer) handleListener(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&m.listenCount, 1)
+	if !m.authorized(w, r) {
+		return
+	}
+	_ = r.ParseForm()
+
+	line := strings.TrimSuffix(r.PostForm.Get("Listening-Configs"), lineSeparator)
+	words := strings.Split(line, wordSeparator)
+	var tenant string
+	if len(words) > 3 {
+		tenant = words[3]
+	}
+	id := configID(words[0], words[1], tenant)
+
+	check := func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return md5Hex(m.configs[id]) != words[2]
+	}
+
+	deadline := time.After(m.pollDuration)
+	for !check() {
+		m.mu.Lock()
+		changed := m.changed
+		m.mu.Unlock()
+		select {
+		case <-changed:
+		case <-deadline:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	_, _ = w.Write([]byte(url.QueryEscape(line + lineSeparator)))
+}
+
+func newTestSubscriber(t *testing.T, conf NacosConf) *NacosSubscriber {
+	sub, err := NewNacosSubscriber(conf)
+	if err != nil {
+		t.Fatalf("NewNacosSubscriber error: %v", err)
+	}
+	t.Cleanup(sub.Stop)
+	return sub
+}
+
+func TestConfValidate(t *testing.T) {
+	c := Conf{}
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for empty host")
+	}
+
+	c = Conf{Host: "127.0.0.1:8848"}
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for empty data id")
+	}
+
+	c = Conf{Host: "127.0.0.1:8848", DataId: "demo"}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate error: %v", err)
+	}
+	if c.Scheme != "http" || c.ContextPath != "/nacos" || c.Group != "DEFAULT_GROUP" || c.Type != "yaml" || c.Timeout != 30000 {
+		t.Errorf("defaults not filled: %+v", c)
+	}
+
+	c = Conf{Host: "127.0.0.1:8848", DataId: "demo", Type: "xml"}
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for unknown type")
+	}
+}
+
+func TestMustNewNacosSubscriber(t *testing.T) {
+	server := newMockNacosServer(t)
+	sub := MustNewNacosSubscriber(NacosConf{Host: server.host(), DataId: "demo"})
+	defer sub.Stop()
+
+	if sub.DataId != "demo" || sub.Group != "DEFAULT_GROUP" || sub.Type != "yaml" {
+		t.Errorf("unexpected subscriber fields: %+v", sub)
+	}
+}
+
+func TestNewNacosSubscriber_InvalidConf(t *testing.T) {
+	if _, err := NewNacosSubscriber(NacosConf{DataId: "demo"}); err == nil {
+		t.Fatal("expected error for empty host")
+	}
+}
+
+func TestValue_Types(t *testing.T) {
+	tests := []struct {
+		typ     string
+		content string
+	}{
+		{typ: "yaml", content: "name: tom\nredis:\n  host: 127.0.0.1:6379\n"},
+		{typ: "json", content: `{"name":"tom","redis":{"host":"127.0.0.1:6379"}}`},
+		{typ: "properties", content: "name=tom\nredis.host=127.0.0.1:6379\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			server := newMockNacosServer(t)
+			server.setConfig("demo", "DEFAULT_GROUP", "", tt.content)
+			sub := newTestSubscriber(t, NacosConf{Host: server.host(), DataId: "demo", Type: tt.typ})
+
+			v, err := sub.Value()
+			if err != nil {
+				t.Fatalf("Value() error: %v", err)
+			}
+
+			var settings struct {
+				Name  string
+				Redis struct {
+					Host string
+				}
+			}
+			if err := json.Unmarshal([]byte(v), &settings); err != nil {
+				t.Fatalf("Value() = %q is not json: %v", v, err)
+			}
+			if settings.Name != "tom" || settings.Redis.Host != "127.0.0.1:6379" {
+				t.Errorf("Value() = %q, want name tom and redis host", v)
+			}
+		})
+	}
+}
+
+func TestValue_PropertiesTypes(t *testing.T) {
+	server := newMockNacosServer(t)
+	content := "name=tom\nport=8080\nratio=0.5\ndebug=true\ncode=007\nredis.host=127.0.0.1:6379\n"
+	server.setConfig("demo", "DEFAULT_GROUP", "", content)
+	sub := newTestSubscriber(t, NacosConf{Host: server.host(), DataId: "demo", Type: "properties"})
+
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+
+	var settings struct {
+		Name  string
+		Port  int
+		Ratio float64
+		Debug bool
+		Code  string
+		Redis struct {
+			Host string
+		}
+	}
+	if err := conf.LoadFromJsonBytes([]byte(v), &settings); err != nil {
+		t.Fatalf("LoadFromJsonBytes(%q) error: %v", v, err)
+	}
+	if settings.Port != 8080 || settings.Ratio != 0.5 || !settings.Debug || settings.Code != "007" ||
+		settings.Redis.Host != "127.0.0.1:6379" {
+		t.Errorf("Value() = %q loaded as %+v", v, settings)
+	}
+}
+
+func TestPropertyValue(t *testing.T) {
+	tests := map[string]any{
+		"8080":                  int64(8080),
+		"-3":                    int64(-3),
+		"1.5":                   1.5,
+		"1e3":                   1000.0,
+		"12345678901234567890":  "12345678901234567890",
+		"-98765432109876543210": "-98765432109876543210",
+		"0.5":                   0.5,
+		"TRUE":                  true,
+		"false":                 false,
+		"007":                   "007",
+		"+1":                    "+1",
+		"0x10":                  "0x10",
+		"NaN":                   "NaN",
+		"tom":                   "tom",
+		"":                      "",
+	}
+	for val, want := range tests {
+		if got := propertyValue(val); got != want {
+			t.Errorf("propertyValue(%q) = %v (%T), want %v (%T)", val, got, got, want, want)
+		}
+	}
+}
+
+func TestValue_NamespaceAndGroup(t *testing.T) {
+	server := newMockNacosServer(t)
+	server.setConfig("demo", "DEFAULT_GROUP", "", "env: public")
+	server.setConfig("demo", "ORDER", "dev", "env: dev")
+	sub := newTestSubscriber(t, NacosConf{Host: server.host(), DataId: "demo", Group: "ORDER", Namespace: "dev"})
+
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if v != `{"env":"dev"}` {
+		t.Errorf("Value() = %q, want dev config", v)
+	}
+}
+
+func TestValue_NotFound(t *testing.T) {
+	server := newMockNacosServer(t)
+	sub := newTestSubscriber(t, NacosConf{Host: server.host(), DataId: "missing"})
+
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if v != "" {
+		t.Errorf("Value() = %q, want empty string", v)
+	}
+}
+
+func TestValue_InvalidContent(t *testing.T) {
+	server := newMockNacosServer(t)
+	server.setConfig("demo", "DEFAULT_GROUP", "", "{invalid yaml: ")
+	sub := newTestSubscriber(t, NacosConf{Host: server.host(), DataId: "demo"})
+
+	if _, err := sub.Value(); err == nil {
+		t.Fatal("expected error for invalid yaml content")
+	}
+}
+
+func TestValue_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	sub := newTestSubscriber(t, NacosConf{Host: strings.TrimPrefix(server.URL, "http://"), DataId: "demo"})
+
+	if _, err := sub.Value(); err == nil {
+		t.Fatal("expected error when nacos returns 500")
+	}
+}
+
+func TestValue_Auth(t *testing.T) {
+	server := newMockNacosServer(t)
+	server.enableAuth("nacos", "secret")
+	server.setConfig("demo", "DEFAULT_GROUP", "", "name: tom")
+	sub := newTestSubscriber(t, NacosConf{Host: server.host(), DataId: "demo", Username: "nacos", Password: "secret"})
+
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if v != `{"name":"tom"}` {
+		t.Errorf("Value() = %q, want name tom", v)
+	}
+
+	// a rejected token is replaced by logging in again
+	server.revokeTokens()
+	before := atomic.LoadInt32(&server.logins)
+	if _, err = sub.Value(); err != nil {
+		t.Fatalf("Value() after token revocation error: %v", err)
+	}
+	if atomic.LoadInt32(&server.logins) <= before {
+		t.Error("expected a new login after token revocation")
+	}
+}
+
+func TestValue_AuthFailed(t *testing.T) {
+	server := newMockNacosServer(t)
+	server.enableAuth("nacos", "secret")
+	server.setConfig("demo", "DEFAULT_GROUP", "", "name: tom")
+	sub := newTestSubscriber(t, NacosConf{Host: server.host(), DataId: "demo", Username: "nacos", Password: "wrong"})
+
+	if _, err := sub.Value(); err == nil {
+		t.Fatal("expected error for wrong password")
+	}
+}
+
+func TestValue_Snapshot(t *testing.T) {
+	server := newMockNacosServer(t)
+	server.setConfig("demo", "ORDER", "dev", "name: tom")
+	dir := t.TempDir()
+	sub := newTestSubscriber(t, NacosConf{
+		Host:        server.host(),
+		DataId:      "demo",
+		Group:       "ORDER",
+		Namespace:   "dev",
+		SnapshotDir: dir,
+	})
+
+	if _, err := sub.Value(); err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "dev", "ORDER", "demo"))
+	if err != nil {
+		t.Fatalf("snapshot not written: %v", err)
+	}
+	if string(content) != "name: tom" {
+		t.Errorf("snapshot = %q, want raw content", content)
+	}
+
+	// nacos goes away, the snapshot is served
+	sub.Stop()
+	server.Close()
+	sub = newTestSubscriber(t, NacosConf{
+		Host:        server.host(),
+		DataId:      "demo",
+		Group:       "ORDER",
+		Namespace:   "dev",
+		SnapshotDir: dir,
+	})
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() with snapshot error: %v", err)
+	}
+	if v != `{"name":"tom"}` {
+		t.Errorf("Value() = %q, want snapshot content", v)
+	}
+}
+
+func TestValue_NoSnapshot(t *testing.T) {
+	server := newMockNacosServer(t)
+	server.Close()
+	sub := newTestSubscriber(t, NacosConf{Host: server.host(), DataId: "demo", SnapshotDir: t.TempDir()})
+
+	if _, err := sub.Value(); err == nil {
+		t.Fatal("expected error without nacos and snapshot")
+	}
+}
+
+func TestAddListener(t *testing.T) {
+	server := newMockNacosServer(t)
+	sub := newTestSubscriber(t, NacosConf{Host: server.host(), DataId: "demo"})
+
+	called := make(chan struct{})
+	if err := sub.AddListener(func() {
+		close(called)
+	}); err != nil {
+		t.Fatalf("AddListener error: %v", err)
+	}
+
+	sub.notifyListeners()
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("listener was not called")
+	}
+}
+
+func TestWatch_TriggersListener(t *testing.T) {
+	server := newMockNacosServer(t)
+	server.setConfig("demo", "DEFAULT_GROUP", "", "name: tom")
+	dir := t.TempDir()
+	sub := newTestSubscriber(t, NacosConf{Host: server.host(), DataId: "demo", SnapshotDir: dir})
+
+	called := make(chan struct{}, 1)
+	if err := sub.AddListener(func() {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+	}); err != nil {
+		t.Fatalf("AddListener error: %v", err)
+	}
+
+	// Give the watch goroutine time to make the initial request.
+	time.Sleep(300 * time.Millisecond)
+
+	server.setConfig("demo", "DEFAULT_GROUP", "", "name: jerry")
+
+	select {
+	case <-called:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watch did not trigger listener after config update")
+	}
+
+	v, err := sub.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if v != `{"name":"jerry"}` {
+		t.Errorf("Value() = %q, want updated config", v)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, "public", "DEFAULT_GROUP", "demo"))
+	if string(content) != "name: jerry" {
+		t.Errorf("snapshot = %q, want updated content", content)
+	}
+}
+
+func TestWatch_Auth(t *testing.T) {
+	server := newMockNacosServer(t)
+	server.enableAuth("nacos", "secret")
+	server.setConfig("demo", "DEFAULT_GROUP", "", "name: tom")
+	sub := newTestSubscriber(t, NacosConf{Host: server.host(), DataId: "demo", Username: "nacos", Password: "secret"})
+
+	called := make(chan struct{}, 1)
+	_ = sub.AddListener(func() {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+	})
+
+	time.Sleep(300 * time.Millisecond)
+	server.revokeTokens()
+	server.setConfig("demo", "DEFAULT_GROUP", "", "name: jerry")
+
+	select {
+	case <-called:
+	case <-time.After(3 * time.Second):
+		t.Fatal("watch did not recover after token revocation")
+	}
+}
+
+func TestStop(t *testing.T) {
+	server := newMockNacosServer(t)
+	server.pollDuration = 5 * time.Second
+	sub, err := NewNacosSubscriber(NacosConf{Host: server.host(), DataId: "demo"})
+	if err != nil {
+		t.Fatalf("NewNacosSubscriber error: %v", err)
+	}
+
+	// Wait for the long polling request to be in flight.
+	time.Sleep(200 * time.Millisecond)
+	before := atomic.LoadInt32(&server.listenCount)
+	sub.Stop()
+	sub.Stop()
+
+	time.Sleep(500 * time.Millisecond)
+	after := atomic.LoadInt32(&server.listenCount)
+	if after != before {
+		t.Errorf("watch did not stop, listen count grew from %d to %d", before, after)
+	}
+}
@@ -20,6 +20,8 @@ export default defineConfig({
               { text: 'Snake', link: '/en/modules/snake' },
               { text: 'Cron', link: '/en/modules/cron' },
               { text: 'ConfigCenter (Consul)', link: '/en/modules/configcenter' },
+              { text: 'ConfigCenter (Nacos)', link: '/en/modules/configcenter-nacos' },
+              { text: 'ConfigCenter (Chain)', link: '/en/modules/configcenter-chain' },
               { text: 'RegisterCenter (Consul)', link: '/en/modules/registercenter' },
               { text: 'RabbitMQ', link: '/en/modules/rabbitmq' },
               { text: 'Aliyun Gateway', link: '/en/modules/aliyun-gateway' },
@@ -47,6 +49,8 @@ export default defineConfig({
                 { text: 'Snake', link: '/en/modules/snake' },
                 { text: 'Cron', link: '/en/modules/cron' },
                 { text: 'ConfigCenter (Consul)', link: '/en/modules/configcenter' },
+                { text: 'ConfigCenter (Nacos)', link: '/en/modules/configcenter-nacos' },
+                { text: 'ConfigCenter (Chain)', link: '/en/modules/configcenter-chain' },
                 { text: 'RegisterCenter (Consul)', link: '/en/modules/registercenter' },
                 { text: 'RabbitMQ', link: '/en/modules/rabbitmq' },
                 { text: 'Aliyun Gateway', link: '/en/modules/aliyun-gateway' },
@@ -77,6 +81,8 @@ export default defineConfig({
               { text: 'Snake 分布式 ID', link: '/zh/modules/snake' },
               { text: 'Cron 定时任务', link: '/zh/modules/cron' },
               { text: '配置中心 (Consul)', link: '/zh/modules/configcenter' },
+              { text: '配置中心 (Nacos)', link: '/zh/modules/configcenter-nacos' },
+              { text: '配置中心 (配置链)', link: '/zh/modules/configcenter-chain' },
               { text: '注册中心 (Consul)', link: '/zh/modules/registercenter' },
               { text: 'RabbitMQ 消息队列', link: '/zh/modules/rabbitmq' },
               { text: '阿里云网关', link: '/zh/modules/aliyun-gateway' },
@@ -104,6 +110,8 @@ export default defineConfig({
                 { text: 'Snake 分布式 ID', link: '/zh/modules/snake' },
                 { text: 'Cron 定时任务', link: '/zh/modules/cron' },
                 { text: '配置中心 (Consul)', link: '/zh/modules/configcenter' },
+                { text: '配置中心 (Nacos)', link: '/zh/modules/configcenter-nacos' },
+                { text: '配置中心 (配置链)', link: '/zh/modules/configcenter-chain' },
                 { text: '注册中心 (Consul)', link: '/zh/modules/registercenter' },
                 { text: 'RabbitMQ 消息队列', link: '/zh/modules/rabbitmq' },
                 { text: '阿里云网关', link: '/zh/modules/aliyun-gateway' },
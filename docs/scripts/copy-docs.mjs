@@ -13,6 +13,10 @@ const copyPairs = [
   { src: '../cron/readme-cn.md', dst: 'zh/modules/cron.md' },
   { src: '../configcenter/consul/README.md', dst: 'en/modules/configcenter.md' },
   { src: '../configcenter/consul/readme-cn.md', dst: 'zh/modules/configcenter.md' },
+  { src: '../configcenter/nacos/README.md', dst: 'en/modules/configcenter-nacos.md' },
+  { src: '../configcenter/nacos/readme-cn.md', dst: 'zh/modules/configcenter-nacos.md' },
+  { src: '../configcenter/chain/README.md', dst: 'en/modules/configcenter-chain.md' },
+  { src: '../configcenter/chain/readme-cn.md', dst: 'zh/modules/configcenter-chain.md' },
   { src: '../registercenter/consul/README.md', dst: 'en/modules/registercenter.md' },
   { src: '../registercenter/consul/readme-cn.md', dst: 'zh/modules/registercenter.md' },
   { src: '../mq/rabbitmq/README.md', dst: 'en/modules/rabbitmq.md' },
@@ -33,6 +37,8 @@ const changelogSections = {
       { title: 'Snake', src: '../snake/CHANGELOG.md' },
       { title: 'Cron', src: '../cron/CHANGELOG.md' },
       { title: 'ConfigCenter (Consul)', src: '../configcenter/consul/CHANGELOG.md' },
+      { title: 'ConfigCenter (Nacos)', src: '../configcenter/nacos/CHANGELOG.md' },
+      { title: 'ConfigCenter (Chain)', src: '../configcenter/chain/CHANGELOG.md' },
       { title: 'RegisterCenter (Consul)', src: '../registercenter/consul/CHANGELOG.md' },
       { title: 'RabbitMQ', src: '../mq/rabbitmq/CHANGELOG.md' },
       { title: 'Aliyun Gateway', src: '../aliyun/gateway/CHANGELOG.md' },
@@ -47,6 +53,8 @@ const changelogSections = {
       { title: 'Snake', src: '../snake/changelog-cn.md' },
       { title: 'Cron', src: '../cron/changelog-cn.md' },
       { title: 'ConfigCenter (Consul)', src: '../configcenter/consul/changelog-cn.md' },
+      { title: 'ConfigCenter (Nacos)', src: '../configcenter/nacos/changelog-cn.md' },
+      { title: 'ConfigCenter (Chain)', src: '../configcenter/chain/changelog-cn.md' },
       { title: 'RegisterCenter (Consul)', src: '../registercenter/consul/changelog-cn.md' },
       { title: 'RabbitMQ', src: '../mq/rabbitmq/changelog-cn.md' },
       { title: 'Aliyun Gateway', src: '../aliyun/gateway/changelog-cn.md' },
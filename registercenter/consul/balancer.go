@@ -0,0 +1,104 @@
+package consul
+
+import (
+	"sync"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/resolver"
+)
+
+// BalancerName is the name of the weighted and zone-aware balancer,
+// select it with a service config such as
+// `{"loadBalancingConfig":[{"consul_weighted":{}}]}`.
+const BalancerName = "consul_weighted"
+
+func init() {
+	balancer.Register(newBalancerBuilder())
+}
+
+func newBalancerBuilder() balancer.Builder {
+	return base.NewBalancerBuilder(BalancerName, &weightedPickerBuilder{}, base.Config{HealthCheck: true})
+}
+
+type (
+	// weightedPickerBuilder builds weightedPicker of the ready SubConns.
+	weightedPickerBuilder struct{}
+
+	// weightedPicker picks the local endpoints by smooth weighted round-robin,
+	// and falls back to the endpoints of the other zones if none is local.
+	weightedPicker struct {
+		conns []*weightedConn
+		lock  sync.Mutex
+	}
+
+	weightedConn struct {
+		conn    balancer.SubConn
+		weight  int
+		current int
+	}
+)
+
+func (b *weightedPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+
+	var local, remote []*weightedConn
+	for conn, connInfo := range info.ReadySCs {
+		wc := &weightedConn{
+			conn:   conn,
+			weight: addressWeight(connInfo.Address),
+		}
+		if addressLocal(connInfo.Address) {
+			local = append(local, wc)
+		} else {
+			remote = append(remote, wc)
+		}
+	}
+
+	if len(local) > 0 {
+		return &weightedPicker{conns: local}
+	}
+
+	return &weightedPicker{conns: remote}
+}
+
+// Pick picks the SubConn with the highest current weight, see
+// https://github.com/phusion/nginx/commit/27e94984486058d73157038f7950a0a36ecc6e35
+func (p *weightedPicker) Pick(_ balancer.PickInfo) (balancer.PickResult, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var total int
+	var best *weightedConn
+	for _, c := range p.conns {
+		c.current += c.weight
+		total += c.weight
+		if best == nil || c.current > best.current {
+			best = c
+		}
+	}
+	best.current -= total
+
+	return balancer.PickResult{SubConn: best.conn}, nil
+}
+
+func addressWeight(addr resolver.Address) int {
+	if addr.Attributes != nil {
+		if weight, ok := addr.Attributes.Value(consulWeight).(int); ok && weight > 0 {
+			return weight
+		}
+	}
+
+	return defaultWeight
+}
+
+func addressLocal(addr resolver.Address) bool {
+	if addr.Attributes == nil {
+		return false
+	}
+
+	local, _ := addr.Attributes.Value(consulLocal).(bool)
+	return local
+}
@@ -28,7 +28,12 @@ func (b *builder) Build(url resolver.Target, cc resolver.ClientConn, opts resolv
 	ctx, cancel := context.WithCancel(context.Background())
 	pipe := make(chan []*consulAddr)
 	go watchConsulService(ctx, cli.Health(), tgt, pipe)
-	go populateEndpoints(ctx, cc, pipe)
+	var configs chan string
+	if len(tgt.ServiceConfig) > 0 {
+		configs = make(chan string)
+		go watchServiceConfig(ctx, cli.KV(), tgt, configs)
+	}
+	go populateEndpoints(ctx, cc, pipe, configs)
 
 	return &resolvr{cancelFunc: cancel}, nil
 }
@@ -0,0 +1,99 @@
+package consul
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// healthCheck is a health check of the registration. ID and ConsulName identify it
+// in Consul, while CheckConf.Name is the short name it's configured with.
+type healthCheck struct {
+	CheckConf
+	ID         string
+	ConsulName string
+}
+
+// healthChecks returns the health checks of the registration. Without Conf.Checks,
+// the single check of Conf.CheckType is identified by the service ID, as it always was.
+func (cc *CommonClient) healthChecks() ([]healthCheck, error) {
+	c := cc.consulConf
+	if len(c.Checks) == 0 {
+		check := healthCheck{
+			CheckConf: CheckConf{
+				Type:     c.CheckType,
+				Name:     c.CheckType,
+				Interval: c.TTL,
+				Timeout:  c.CheckTimeout,
+				Http:     c.CheckHttp,
+				Grpc:     c.CheckGrpc,
+			},
+			ID:         cc.serviceId,
+			ConsulName: c.Key,
+		}
+		if !knownCheckType(check.Type) {
+			return nil, fmt.Errorf("unknown check type: %s", check.Type)
+		}
+		return []healthCheck{check}, nil
+	}
+
+	checks := make([]healthCheck, 0, len(c.Checks))
+	for _, check := range c.Checks {
+		if !knownCheckType(check.Type) {
+			return nil, fmt.Errorf("unknown check type: %s", check.Type)
+		}
+		checks = append(checks, healthCheck{
+			CheckConf:  check,
+			ID:         fmt.Sprintf("%s:%s", cc.serviceId, check.Name),
+			ConsulName: fmt.Sprintf("%s:%s", c.Key, check.Name),
+		})
+	}
+
+	return checks, nil
+}
+
+// agentServiceCheck returns the Consul definition of check with the given status.
+func (cc *CommonClient) agentServiceCheck(check healthCheck, status string) *api.AgentServiceCheck {
+	asc := &api.AgentServiceCheck{
+		CheckID:                        check.ID,
+		Name:                           check.ConsulName,
+		Status:                         status,
+		DeregisterCriticalServiceAfter: fmt.Sprintf("%ds", check.Interval*cc.consulConf.ExpiredTTL), // Deregistration time
+	}
+
+	switch check.Type {
+	case CheckTypeTTL:
+		asc.TTL = fmt.Sprintf("%ds", check.Interval) // Health check interval
+		return asc
+	case CheckTypeGrpc:
+		asc.GRPC = fmt.Sprintf("%s:%d", cc.serviceHost, cc.servicePort) // health check method
+		asc.TLSServerName = check.Grpc.TLSServerName
+		asc.TLSSkipVerify = check.Grpc.TLSSkipVerify
+		asc.GRPCUseTLS = check.Grpc.GRPCUseTLS
+	case CheckTypeHttp:
+		httpCheckHost := figureOutListenOn(fmt.Sprintf("%s:%d", check.Http.Host, check.Http.Port))
+		asc.HTTP = fmt.Sprintf("%s://%s%s", check.Http.Scheme, httpCheckHost, check.Http.Path) // health check url
+		asc.Method = check.Http.Method                                                         // health check method
+	case CheckTypeTcp:
+		host, port := check.Tcp.Host, check.Tcp.Port
+		if port == 0 {
+			host, port = cc.serviceHost, cc.servicePort
+		} else if len(host) == 0 {
+			host = allEths
+		}
+		asc.TCP = figureOutListenOn(fmt.Sprintf("%s:%d", host, port))
+	}
+	asc.Interval = fmt.Sprintf("%ds", check.Interval) // health check interval
+	asc.Timeout = fmt.Sprintf("%ds", check.Timeout)   // health check timeout
+
+	return asc
+}
+
+func knownCheckType(typ string) bool {
+	switch typ {
+	case CheckTypeTTL, CheckTypeGrpc, CheckTypeHttp, CheckTypeTcp:
+		return true
+	default:
+		return false
+	}
+}
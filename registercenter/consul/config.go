@@ -9,11 +9,22 @@ const (
 	allEths       = "0.0.0.0"
 	envPodIP      = "POD_IP"
 	consulTags    = "consul_tags"
+	consulMeta    = "consul_meta"
+	consulWeight  = "consul_weight"
+	consulZone    = "consul_zone"
+	consulLocal   = "consul_local"
+	defaultWeight = 10
 	CheckTypeTTL  = "ttl"
 	CheckTypeHttp = "http"
 	CheckTypeGrpc = "grpc"
+	CheckTypeTcp  = "tcp"
 	healthPort    = 6060
 	healthPath    = "/healthz"
+
+	// MetaWeight is the service meta key of the endpoint weight, read by the consul_weighted balancer.
+	MetaWeight = "weight"
+	// MetaZone is the service meta key of the endpoint zone, read by the consul_weighted balancer.
+	MetaZone = "zone"
 )
 
 // CheckHttpConf is the http check config.
@@ -32,6 +43,36 @@ type CheckGrpcConf struct {
 	GRPCUseTLS    bool   `json:",default=false"`
 }
 
+// CheckTcpConf is the tcp check config, the service address is checked if Port is 0.
+type CheckTcpConf struct {
+	Host string `json:",optional"`
+	Port int    `json:",optional"`
+}
+
+// CheckConf is one of several health checks of the registration.
+// Name identifies the check, it defaults to Type, so it must be set
+// if several checks are of the same type.
+// Interval is the check interval, or the TTL of a ttl check, it defaults to Conf.TTL.
+// Timeout is the timeout of http, grpc and tcp checks, it defaults to Conf.CheckTimeout.
+type CheckConf struct {
+	Type     string        `json:",options=ttl|grpc|http|tcp"`
+	Name     string        `json:",optional"`
+	Interval int           `json:",optional"`
+	Timeout  int           `json:",optional"`
+	Http     CheckHttpConf `json:",optional"`
+	Grpc     CheckGrpcConf `json:",optional"`
+	Tcp      CheckTcpConf  `json:",optional"`
+}
+
+// HealthServerConf is the built-in health server config, it serves the
+// readiness of the service at Path, the default address of CheckHttpConf.
+type HealthServerConf struct {
+	Enabled bool   `json:",optional"`
+	Host    string `json:",default=0.0.0.0"`
+	Port    int    `json:",default=6060"`
+	Path    string `json:",default=/healthz"`
+}
+
 // Conf is the config item with the given key on consul
 // Host is the consul hosts. example: "localhost:8500"
 // Key is the consul key. example: "service/name"
@@ -45,19 +86,29 @@ type CheckGrpcConf struct {
 // CheckHttp is the http check config.
 // CheckGrpc is the grpc check config.
 // CheckTypeTTL is the ttl check config.
+// Checks are several health checks registered together, replacing CheckType if set.
+// HealthServer is the built-in health server config.
+// DrainGrace is the seconds to drain the service before deregistration on shutdown. example: 3
+// Weight is the balancer weight published as meta. example: 10
+// Zone is the zone published as meta. example: "cn-hangzhou-a"
 type Conf struct {
 	Host         string            // consul hosts
 	Key          string            // consul key
-	Scheme       string            `json:",default=http,options=http|https"`   // consul scheme
-	Token        string            `json:",optional"`                          // consul token
-	Tag          []string          `json:",optional"`                          // consul tags
-	Meta         map[string]string `json:",optional"`                          // consul meta
-	TTL          int               `json:",default=20"`                        // live check interval
-	ExpiredTTL   int               `json:",default=3"`                         // Deregistration time multiplier. The actual deregistration time is calculated as TTL*ExpiredTTL in seconds.
-	CheckTimeout int               `json:",default=3"`                         // health check timeout, http or grpc check timeout, ttl unuse
-	CheckType    string            `json:",default=ttl,options=ttl|grpc|http"` // check type, ttl, http or grpc
+	Scheme       string            `json:",default=http,options=http|https"`       // consul scheme
+	Token        string            `json:",optional"`                              // consul token
+	Tag          []string          `json:",optional"`                              // consul tags
+	Meta         map[string]string `json:",optional"`                              // consul meta
+	TTL          int               `json:",default=20"`                            // live check interval
+	ExpiredTTL   int               `json:",default=3"`                             // Deregistration time multiplier. The actual deregistration time is calculated as TTL*ExpiredTTL in seconds.
+	CheckTimeout int               `json:",default=3"`                             // health check timeout, http or grpc check timeout, ttl unuse
+	CheckType    string            `json:",default=ttl,options=ttl|grpc|http|tcp"` // check type, ttl, http, grpc or tcp
 	CheckHttp    CheckHttpConf
 	CheckGrpc    CheckGrpcConf
+	Checks       []CheckConf      `json:",optional"` // several checks, e.g. ttl heartbeat + http readiness + tcp liveness
+	Weight       int              `json:",optional"` // balancer weight, published as meta weight
+	Zone         string           `json:",optional"` // zone of the instance, published as meta zone
+	HealthServer HealthServerConf `json:",optional"` // built-in health server, serving the readiness probes
+	DrainGrace   int              `json:",optional"` // seconds in maintenance mode before deregistration on shutdown
 }
 
 // Validate validates c.
@@ -89,29 +140,87 @@ func (c *Conf) Validate() error {
 		c.Scheme = "http"
 	}
 
+	if c.Weight < 0 {
+		return fmt.Errorf("negative weight: %d", c.Weight)
+	}
+
 	switch c.CheckType {
 	case CheckTypeTTL:
 	case CheckTypeGrpc:
+	case CheckTypeTcp:
 	case CheckTypeHttp:
-		if c.CheckHttp.Scheme == "" {
-			c.CheckHttp.Scheme = "http"
-		}
-		if c.CheckHttp.Method == "" {
-			c.CheckHttp.Method = "GET"
+		c.CheckHttp.setDefaults()
+	default:
+		return fmt.Errorf("unknown check type: %s", c.CheckType)
+
+	}
+
+	if c.DrainGrace < 0 {
+		return fmt.Errorf("negative drain grace: %d", c.DrainGrace)
+	}
+
+	if c.HealthServer.Enabled {
+		c.HealthServer.setDefaults()
+	}
+
+	// copy the checks to not modify the slice of the caller
+	c.Checks = append([]CheckConf(nil), c.Checks...)
+	names := make(map[string]bool, len(c.Checks))
+	for i := range c.Checks {
+		check := &c.Checks[i]
+		if len(check.Name) == 0 {
+			check.Name = check.Type
 		}
-		if c.CheckHttp.Path == "" {
-			c.CheckHttp.Path = healthPath
+		if names[check.Name] {
+			return fmt.Errorf("duplicate check name: %s", check.Name)
 		}
-		if c.CheckHttp.Port == 0 {
-			c.CheckHttp.Port = healthPort
+		names[check.Name] = true
+
+		if check.Interval == 0 {
+			check.Interval = c.TTL
 		}
-		if c.CheckHttp.Host == "" {
-			c.CheckHttp.Host = fmt.Sprintf("0.0.0.0")
+		if check.Timeout == 0 {
+			check.Timeout = c.CheckTimeout
 		}
-	default:
-		return fmt.Errorf("unknown check type: %s", c.CheckType)
 
+		switch check.Type {
+		case CheckTypeTTL, CheckTypeGrpc, CheckTypeTcp:
+		case CheckTypeHttp:
+			check.Http.setDefaults()
+		default:
+			return fmt.Errorf("unknown check type: %s", check.Type)
+		}
 	}
 
 	return nil
 }
+
+func (c *CheckHttpConf) setDefaults() {
+	if c.Scheme == "" {
+		c.Scheme = "http"
+	}
+	if c.Method == "" {
+		c.Method = "GET"
+	}
+	if c.Path == "" {
+		c.Path = healthPath
+	}
+	if c.Port == 0 {
+		c.Port = healthPort
+	}
+	if c.Host == "" {
+		c.Host = allEths
+	}
+}
+
+func (c *HealthServerConf) setDefaults() {
+	if c.Host == "" {
+		c.Host = allEths
+	}
+	if c.Port == 0 {
+		c.Port = healthPort
+	}
+	if c.Path == "" {
+		c.Path = healthPath
+	}
+}
@@ -3,12 +3,17 @@ package consul
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -19,7 +24,10 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zeromicro/go-zero/core/proc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
 	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
 )
 
 // ─────────────────────────────────────────────
@@ -947,7 +955,7 @@ func TestPopulateEndpoints_Basic(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go populateEndpoints(ctx, cc, input)
+	go populateEndpoints(ctx, cc, input, nil)
 
 	input <- []*consulAddr{
 		{Addr: "10.0.0.2", Port: 9000, Tags: []string{"v2"}},
@@ -975,7 +983,7 @@ func TestPopulateEndpoints_TagsSet(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go populateEndpoints(ctx, cc, input)
+	go populateEndpoints(ctx, cc, input, nil)
 
 	input <- []*consulAddr{
 		{Addr: "10.0.0.1", Port: 8080, Tags: []string{"tag1", "tag2"}},
@@ -1001,7 +1009,7 @@ func TestPopulateEndpoints_ContextCancel(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		populateEndpoints(ctx, cc, input)
+		populateEndpoints(ctx, cc, input, nil)
 		close(done)
 	}()
 
@@ -1620,3 +1628,1235 @@ func TestHttpCheckMonitorFunc_TickerFires(t *testing.T) {
 		t.Fatal("monitor did not stop")
 	}
 }
+
+// ─────────────────────────────────────────────
+// balancer.go – weighted and zone-aware picker
+// ─────────────────────────────────────────────
+
+type mockSubConn struct {
+	balancer.SubConn
+	name string
+}
+
+func weightedAddress(addr string, weight int, local bool) resolver.Address {
+	return resolver.Address{
+		Addr:       addr,
+		Attributes: addressAttributes(&consulAddr{Weight: weight, Local: local}),
+	}
+}
+
+func pickCounts(t *testing.T, p balancer.Picker, n int) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		res, err := p.Pick(balancer.PickInfo{})
+		require.NoError(t, err)
+		counts[res.SubConn.(*mockSubConn).name]++
+	}
+	return counts
+}
+
+func TestWeightedPicker_NoReadySubConns(t *testing.T) {
+	p := (&weightedPickerBuilder{}).Build(base.PickerBuildInfo{})
+	_, err := p.Pick(balancer.PickInfo{})
+	assert.ErrorIs(t, err, balancer.ErrNoSubConnAvailable)
+}
+
+func TestWeightedPicker_Weights(t *testing.T) {
+	p := (&weightedPickerBuilder{}).Build(base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{
+		&mockSubConn{name: "a"}: {Address: weightedAddress("10.0.0.1:8080", 30, false)},
+		&mockSubConn{name: "b"}: {Address: weightedAddress("10.0.0.2:8080", 10, false)},
+		&mockSubConn{name: "c"}: {Address: resolver.Address{Addr: "10.0.0.3:8080"}}, // default weight
+	}})
+
+	counts := pickCounts(t, p, 50)
+	assert.Equal(t, map[string]int{"a": 30, "b": 10, "c": 10}, counts)
+}
+
+func TestWeightedPicker_PrefersLocal(t *testing.T) {
+	p := (&weightedPickerBuilder{}).Build(base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{
+		&mockSubConn{name: "local"}:  {Address: weightedAddress("10.0.0.1:8080", 1, true)},
+		&mockSubConn{name: "remote"}: {Address: weightedAddress("10.0.0.2:8080", 100, false)},
+	}})
+
+	assert.Equal(t, map[string]int{"local": 10}, pickCounts(t, p, 10))
+}
+
+func TestWeightedPicker_FallbackAcrossZones(t *testing.T) {
+	p := (&weightedPickerBuilder{}).Build(base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{
+		&mockSubConn{name: "a"}: {Address: weightedAddress("10.0.0.1:8080", 10, false)},
+		&mockSubConn{name: "b"}: {Address: weightedAddress("10.0.0.2:8080", 10, false)},
+	}})
+
+	assert.Equal(t, map[string]int{"a": 5, "b": 5}, pickCounts(t, p, 10))
+}
+
+func TestBalancer_Registered(t *testing.T) {
+	assert.NotNil(t, balancer.Get(BalancerName))
+}
+
+func TestWatchConsulService_WeightAndZone(t *testing.T) {
+	entries := []*api.ServiceEntry{
+		{
+			Service: &api.AgentService{Address: "10.0.0.1", Port: 8080, Meta: map[string]string{MetaWeight: "20", MetaZone: "zone-a"}},
+			Node:    &api.Node{Address: "10.0.0.1", Datacenter: "dc1"},
+		},
+		{
+			Service: &api.AgentService{Address: "10.0.0.2", Port: 8080},
+			Node:    &api.Node{Address: "10.0.0.2", Datacenter: "dc2"},
+		},
+	}
+	svc := &mockServicer{entries: entries, meta: &api.QueryMeta{LastIndex: 1}}
+	tgt := target{Service: "test", MaxBackoff: time.Second, Near: "_agent", Zone: "zone-a"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	out := make(chan []*consulAddr, 1)
+	go watchConsulService(ctx, svc, tgt, out)
+
+	select {
+	case addrs := <-out:
+		require.Len(t, addrs, 2)
+		assert.Equal(t, 20, addrs[0].Weight)
+		assert.Equal(t, "zone-a", addrs[0].Zone)
+		assert.True(t, addrs[0].Local)
+		assert.Equal(t, 0, addrs[1].Weight)
+		// datacenter as the zone if no zone meta
+		assert.Equal(t, "dc2", addrs[1].Zone)
+		assert.False(t, addrs[1].Local)
+	case <-ctx.Done():
+		t.Fatal("timed out")
+	}
+}
+
+func TestAddressAttributes(t *testing.T) {
+	assert.Nil(t, addressAttributes(&consulAddr{}))
+
+	attrs := addressAttributes(&consulAddr{Tags: []string{"v1"}, Weight: 5, Zone: "z", Local: true})
+	require.NotNil(t, attrs)
+	assert.Equal(t, "v1", attrs.Value(consulTags))
+	assert.Equal(t, 5, attrs.Value(consulWeight))
+	assert.Equal(t, "z", attrs.Value(consulZone))
+	assert.Equal(t, true, attrs.Value(consulLocal))
+}
+
+func TestClientRegistration_WeightAndZoneMeta(t *testing.T) {
+	srv := fakeConsulServer(t)
+	defer srv.Close()
+
+	meta := map[string]string{"version": "v1"}
+	conf := Conf{
+		Host: srv.Listener.Addr().String(), Key: "svc", CheckType: CheckTypeTTL,
+		TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http",
+		Meta: meta, Weight: 20, Zone: "zone-a",
+	}
+	client, err := NewService("127.0.0.1:6200", conf)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"version": "v1", MetaWeight: "20", MetaZone: "zone-a"}, client.GetRegistration().Meta)
+	// the configured meta is not modified
+	assert.Equal(t, map[string]string{"version": "v1"}, meta)
+}
+
+func TestValidate_NegativeWeight(t *testing.T) {
+	c := Conf{Host: "h:8500", Key: "k", Weight: -1}
+	require.Error(t, c.Validate())
+}
+
+// ─────────────────────────────────────────────
+// resovler.go / serviceconfig.go – meta and service config
+// ─────────────────────────────────────────────
+
+func TestServiceMeta_Equal(t *testing.T) {
+	m := ServiceMeta{"version": "v1"}
+	assert.True(t, m.Equal(ServiceMeta{"version": "v1"}))
+	assert.False(t, m.Equal(ServiceMeta{"version": "v2"}))
+	assert.False(t, m.Equal(ServiceMeta{"version": "v1", "zone": "a"}))
+	assert.False(t, m.Equal(map[string]string{"version": "v1"}))
+}
+
+func TestAddressMeta(t *testing.T) {
+	assert.Nil(t, AddressMeta(resolver.Address{Addr: "10.0.0.1:8080"}))
+
+	addr := resolver.Address{
+		Addr:       "10.0.0.1:8080",
+		Attributes: addressAttributes(&consulAddr{Meta: map[string]string{"version": "v1"}}),
+	}
+	assert.Equal(t, map[string]string{"version": "v1"}, AddressMeta(addr))
+	// equal meta keeps the addresses equal
+	other := resolver.Address{
+		Addr:       "10.0.0.1:8080",
+		Attributes: addressAttributes(&consulAddr{Meta: map[string]string{"version": "v1"}}),
+	}
+	assert.True(t, addr.Equal(other))
+}
+
+type mockKV struct {
+	mu    sync.Mutex
+	calls int
+	pairs []*api.KVPair
+	err   error
+}
+
+func (m *mockKV) Get(key string, q *api.QueryOptions) (*api.KVPair, *api.QueryMeta, error) {
+	m.mu.Lock()
+	m.calls++
+	calls := m.calls
+	m.mu.Unlock()
+
+	if m.err != nil && calls == 1 {
+		return nil, nil, m.err
+	}
+	if calls > len(m.pairs) {
+		// block like a Consul blocking query without changes
+		<-q.Context().Done()
+		return nil, nil, q.Context().Err()
+	}
+	return m.pairs[calls-1], &api.QueryMeta{LastIndex: uint64(calls)}, nil
+}
+
+func TestWatchServiceConfig(t *testing.T) {
+	cfg := `{"loadBalancingPolicy":"round_robin"}`
+	kv := &mockKV{
+		err: fmt.Errorf("consul unavailable"),
+		pairs: []*api.KVPair{
+			nil, // the error
+			{Key: "grpc/svc", Value: []byte(cfg)},
+			{Key: "grpc/svc", Value: []byte(cfg)}, // unchanged, not sent
+			nil,                                   // deleted
+		},
+	}
+	tgt := target{Service: "test", MaxBackoff: 50 * time.Millisecond, ServiceConfig: "grpc/svc"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	out := make(chan string)
+	go watchServiceConfig(ctx, kv, tgt, out)
+
+	for _, want := range []string{cfg, ""} {
+		select {
+		case raw := <-out:
+			assert.Equal(t, want, raw)
+		case <-ctx.Done():
+			t.Fatal("timed out")
+		}
+	}
+}
+
+type parsingClientConn struct {
+	mockClientConn
+}
+
+func (m *parsingClientConn) ParseServiceConfig(raw string) *serviceconfig.ParseResult {
+	if !json.Valid([]byte(raw)) {
+		return &serviceconfig.ParseResult{Err: fmt.Errorf("invalid service config")}
+	}
+	return &serviceconfig.ParseResult{}
+}
+
+func TestPopulateEndpoints_ServiceConfig(t *testing.T) {
+	cc := &parsingClientConn{}
+	input := make(chan []*consulAddr)
+	configs := make(chan string)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go populateEndpoints(ctx, cc, input, configs)
+
+	// received before the endpoints, pushed along with them
+	configs <- `{"loadBalancingPolicy":"round_robin"}`
+	input <- []*consulAddr{{Addr: "10.0.0.1", Port: 8080, Meta: map[string]string{"version": "v1"}}}
+	// ignored
+	configs <- "not json"
+	// deleted, back to the default
+	configs <- ""
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	require.Len(t, cc.states, 2)
+	require.NotNil(t, cc.states[0].ServiceConfig)
+	require.Len(t, cc.states[0].Addresses, 1)
+	assert.Equal(t, map[string]string{"version": "v1"}, AddressMeta(cc.states[0].Addresses[0]))
+	assert.Nil(t, cc.states[1].ServiceConfig)
+	assert.Len(t, cc.states[1].Addresses, 1)
+}
+
+func TestParseURL_ServiceConfig(t *testing.T) {
+	tgt, err := parseURL(mustParseURL("consul://localhost:8500/svc?service-config=grpc/svc&zone=a"))
+	require.NoError(t, err)
+	assert.Equal(t, "grpc/svc", tgt.ServiceConfig)
+	assert.Equal(t, "a", tgt.Zone)
+}
+
+// ─────────────────────────────────────────────
+// discovery.go / roundtripper.go – HTTP client-side discovery
+// ─────────────────────────────────────────────
+
+func serviceEntries(addrs ...string) []*api.ServiceEntry {
+	entries := make([]*api.ServiceEntry, 0, len(addrs))
+	for _, addr := range addrs {
+		host, port, _ := net.SplitHostPort(addr)
+		p, _ := strconv.Atoi(port)
+		entries = append(entries, &api.ServiceEntry{
+			Service: &api.AgentService{Address: host, Port: p},
+			Node:    &api.Node{Address: host},
+		})
+	}
+	return entries
+}
+
+func waitDiscovery(t *testing.T, d *Discovery) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	require.NoError(t, d.Wait(ctx))
+}
+
+func TestNewDiscovery_BadURL(t *testing.T) {
+	_, err := NewDiscovery("http://localhost:8500/svc")
+	require.Error(t, err)
+	_, err = NewDiscovery("consul://localhost:8500")
+	require.Error(t, err)
+}
+
+func TestDiscovery_Pick(t *testing.T) {
+	svc := &mockServicer{
+		entries: serviceEntries("10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.1:8080"),
+		meta:    &api.QueryMeta{LastIndex: 1},
+	}
+	d := newDiscovery(svc, target{Service: "test", MaxBackoff: time.Second})
+	defer d.Close()
+	waitDiscovery(t, d)
+
+	assert.ElementsMatch(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, d.Endpoints())
+	for i := 0; i < 10; i++ {
+		addr, err := d.Pick()
+		require.NoError(t, err)
+		assert.Contains(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, addr)
+	}
+}
+
+func TestDiscovery_PickNoEndpoints(t *testing.T) {
+	svc := &mockServicer{entries: nil, meta: &api.QueryMeta{LastIndex: 1}}
+	d := newDiscovery(svc, target{Service: "test", MaxBackoff: time.Second})
+	defer d.Close()
+	waitDiscovery(t, d)
+
+	_, err := d.Pick()
+	assert.ErrorIs(t, err, ErrNoEndpoints)
+}
+
+func TestDiscovery_PickPrefersLessLoaded(t *testing.T) {
+	d := &Discovery{ready: make(chan struct{})}
+	d.update([]*consulAddr{{Addr: "10.0.0.1", Port: 8080}, {Addr: "10.0.0.2", Port: 8080}})
+	busy := d.endpoints[0]
+	busy.start() // in flight
+
+	for i := 0; i < 10; i++ {
+		addr, err := d.Pick()
+		require.NoError(t, err)
+		assert.Equal(t, "10.0.0.2:8080", addr)
+	}
+}
+
+func TestDiscovery_UpdateKeepsStats(t *testing.T) {
+	d := &Discovery{ready: make(chan struct{})}
+	d.update([]*consulAddr{{Addr: "10.0.0.1", Port: 8080}})
+	e := d.endpoints[0]
+	e.start()()
+
+	d.update([]*consulAddr{{Addr: "10.0.0.1", Port: 8080}, {Addr: "10.0.0.2", Port: 8080}})
+	assert.Same(t, e, d.endpoints[0])
+	assert.Greater(t, atomic.LoadInt64(&e.lag), int64(0))
+}
+
+func TestDiscovery_PickExcluded(t *testing.T) {
+	d := &Discovery{ready: make(chan struct{})}
+	d.update([]*consulAddr{{Addr: "10.0.0.1", Port: 8080}, {Addr: "10.0.0.2", Port: 8080}})
+
+	e, err := d.pick(map[string]bool{"10.0.0.1:8080": true})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2:8080", e.addr)
+
+	// all excluded, any endpoint is picked
+	_, err = d.pick(map[string]bool{"10.0.0.1:8080": true, "10.0.0.2:8080": true})
+	require.NoError(t, err)
+}
+
+func TestNewRoundTripper_BadURL(t *testing.T) {
+	_, err := NewRoundTripper("http://localhost:8500")
+	require.Error(t, err)
+	_, err = NewRoundTripper("consul://localhost:8500?limit=abc")
+	require.Error(t, err)
+}
+
+func newTestRoundTripper(t *testing.T, service string, addrs ...string) *RoundTripper {
+	t.Helper()
+	rt, err := NewRoundTripper("consul://localhost:8500?healthy=true")
+	require.NoError(t, err)
+	svc := &mockServicer{entries: serviceEntries(addrs...), meta: &api.QueryMeta{LastIndex: 1}}
+	rt.discoveries[service] = newDiscovery(svc, target{Service: service, MaxBackoff: time.Second})
+	t.Cleanup(rt.Close)
+	return rt
+}
+
+func TestRoundTripper_RoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", r.URL.Path, body)
+	}))
+	defer srv.Close()
+
+	rt := newTestRoundTripper(t, "user-api", srv.Listener.Addr().String())
+	client := &http.Client{Transport: rt}
+
+	resp, err := client.Post("http://user-api/users", "text/plain", strings.NewReader("hello"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "/users hello", string(body))
+}
+
+func TestRoundTripper_RetriesOtherInstance(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	downAddr := down.Listener.Addr().String()
+	down.Close()
+
+	var hits int32
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer up.Close()
+
+	rt := newTestRoundTripper(t, "user-api", downAddr, up.Listener.Addr().String())
+	client := &http.Client{Transport: rt}
+
+	for i := 0; i < 5; i++ {
+		resp, err := client.Post("http://user-api/users", "text/plain", strings.NewReader("hello"))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "hello", string(body))
+	}
+	assert.Equal(t, int32(5), atomic.LoadInt32(&hits))
+}
+
+func TestRoundTripper_NoRetries(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	downAddr := down.Listener.Addr().String()
+	down.Close()
+
+	rt := newTestRoundTripper(t, "user-api", downAddr)
+	rt.retries = 0
+	_, err := (&http.Client{Transport: rt}).Get("http://user-api/users")
+	require.Error(t, err)
+}
+
+func TestRoundTripper_NonIdempotentRetries(t *testing.T) {
+	// the instance reads the request and drops the connection, it may have processed it
+	var hits int32
+	drop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer drop.Close()
+
+	rt := newTestRoundTripper(t, "user-api", drop.Listener.Addr().String())
+	client := &http.Client{Transport: rt}
+
+	_, err := client.Post("http://user-api/users", "text/plain", strings.NewReader("hello"))
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits), "POST must not be retried once sent")
+
+	_, err = client.Get("http://user-api/users")
+	require.Error(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&hits), "GET is retried")
+
+	WithRetryNonIdempotent()(rt)
+	_, err = client.Post("http://user-api/users", "text/plain", strings.NewReader("hello"))
+	require.Error(t, err)
+	assert.Equal(t, int32(7), atomic.LoadInt32(&hits), "POST is retried once opted in")
+}
+
+func TestRoundTripper_Retryable(t *testing.T) {
+	rt := newTestRoundTripper(t, "user-api")
+	req, err := http.NewRequest(http.MethodPost, "http://user-api/users", strings.NewReader("hello"))
+	require.NoError(t, err)
+
+	assert.False(t, rt.retryable(req, io.EOF))
+	assert.True(t, rt.retryable(req, &net.OpError{Op: "dial", Err: io.EOF}))
+	req.Header.Set("Idempotency-Key", "1")
+	assert.True(t, rt.retryable(req, io.EOF))
+
+	req.GetBody = nil
+	assert.False(t, rt.retryable(req, io.EOF), "body can't be replayed")
+}
+
+func TestRoundTripper_EmptyService(t *testing.T) {
+	rt, err := NewRoundTripper("consul://localhost:8500", WithRetries(1), WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+	assert.Equal(t, 1, rt.retries)
+
+	_, err = rt.RoundTrip(&http.Request{URL: &url.URL{Scheme: "http", Path: "/users"}})
+	require.Error(t, err)
+}
+
+func TestParseURL_TypedParams(t *testing.T) {
+	tgt, err := parseURL(mustParseURL("consul://localhost:8500/svc?healthy=true&limit=2&allow-stale=1&wait=2s"))
+	require.NoError(t, err)
+	assert.True(t, tgt.Healthy)
+	assert.True(t, tgt.AllowStale)
+	assert.Equal(t, 2, tgt.Limit)
+	assert.Equal(t, 2*time.Second, tgt.Wait)
+
+	_, err = parseURL(mustParseURL("consul://localhost:8500/svc?healthy=yes"))
+	require.Error(t, err)
+	_, err = parseURL(mustParseURL("consul://localhost:8500/svc?limit=abc"))
+	require.Error(t, err)
+}
+
+// ─────────────────────────────────────────────
+// check.go – multiple health checks per registration
+// ─────────────────────────────────────────────
+
+func TestValidate_ChecksDefaults(t *testing.T) {
+	checks := []CheckConf{{Type: CheckTypeTTL}, {Type: CheckTypeHttp, Name: "ready", Interval: 5}, {Type: CheckTypeTcp}}
+	c := Conf{Host: "h:8500", Key: "k", TTL: 20, CheckTimeout: 3, CheckType: CheckTypeTTL, Checks: checks}
+	require.NoError(t, c.Validate())
+
+	assert.Equal(t, CheckTypeTTL, c.Checks[0].Name)
+	assert.Equal(t, 20, c.Checks[0].Interval)
+	assert.Equal(t, 3, c.Checks[0].Timeout)
+	assert.Equal(t, "ready", c.Checks[1].Name)
+	assert.Equal(t, 5, c.Checks[1].Interval)
+	assert.Equal(t, healthPath, c.Checks[1].Http.Path)
+	assert.Equal(t, healthPort, c.Checks[1].Http.Port)
+	assert.Equal(t, CheckTypeTcp, c.Checks[2].Name)
+	// the configured checks are not modified
+	assert.Empty(t, checks[0].Name)
+}
+
+func TestValidate_ChecksErrors(t *testing.T) {
+	c := Conf{Host: "h:8500", Key: "k", CheckType: CheckTypeTTL, Checks: []CheckConf{{Type: CheckTypeHttp}, {Type: CheckTypeHttp}}}
+	require.Error(t, c.Validate())
+
+	c = Conf{Host: "h:8500", Key: "k", CheckType: CheckTypeTTL, Checks: []CheckConf{{Type: "udp"}}}
+	require.Error(t, c.Validate())
+}
+
+func TestClientRegistration_MultipleChecks(t *testing.T) {
+	srv := fakeConsulServer(t)
+	defer srv.Close()
+
+	conf := Conf{
+		Host: srv.Listener.Addr().String(), Key: "svc", CheckType: CheckTypeTTL,
+		TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http",
+		Checks: []CheckConf{
+			{Type: CheckTypeTTL},
+			{Type: CheckTypeHttp, Name: "ready", Http: CheckHttpConf{Host: "127.0.0.1", Port: 6061}},
+			{Type: CheckTypeTcp, Interval: 10},
+		},
+	}
+	client, err := NewService("127.0.0.1:6201", conf)
+	require.NoError(t, err)
+
+	checks := client.GetRegistration().Checks
+	require.Len(t, checks, 3)
+
+	assert.Equal(t, "svc-127.0.0.1-6201:ttl", checks[0].CheckID)
+	assert.Equal(t, "svc:ttl", checks[0].Name)
+	assert.Equal(t, "20s", checks[0].TTL)
+	assert.Equal(t, "60s", checks[0].DeregisterCriticalServiceAfter)
+
+	assert.Equal(t, "svc-127.0.0.1-6201:ready", checks[1].CheckID)
+	assert.Equal(t, "http://127.0.0.1:6061/healthz", checks[1].HTTP)
+	assert.Equal(t, "GET", checks[1].Method)
+	assert.Equal(t, "3s", checks[1].Timeout)
+
+	assert.Equal(t, "svc-127.0.0.1-6201:tcp", checks[2].CheckID)
+	assert.Equal(t, "127.0.0.1:6201", checks[2].TCP)
+	assert.Equal(t, "10s", checks[2].Interval)
+	assert.Equal(t, "30s", checks[2].DeregisterCriticalServiceAfter)
+}
+
+func TestClientRegistration_TcpCheck(t *testing.T) {
+	srv := fakeConsulServer(t)
+	defer srv.Close()
+
+	conf := Conf{Host: srv.Listener.Addr().String(), Key: "svc", CheckType: CheckTypeTcp, TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http"}
+	client, err := NewService("127.0.0.1:6202", conf)
+	require.NoError(t, err)
+
+	checks := client.GetRegistration().Checks
+	require.Len(t, checks, 1)
+	assert.Equal(t, "svc-127.0.0.1-6202", checks[0].CheckID)
+	assert.Equal(t, "127.0.0.1:6202", checks[0].TCP)
+	assert.Equal(t, "20s", checks[0].Interval)
+}
+
+func TestSetRegisterServiceHealthStatus_MultipleChecks(t *testing.T) {
+	var lock sync.Mutex
+	var registered []api.AgentCheckRegistration
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/agent/check/register", func(w http.ResponseWriter, r *http.Request) {
+		var reg api.AgentCheckRegistration
+		_ = json.NewDecoder(r.Body).Decode(&reg)
+		lock.Lock()
+		registered = append(registered, reg)
+		lock.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	conf := Conf{
+		Host: srv.Listener.Addr().String(), Key: "svc", CheckType: CheckTypeTTL,
+		TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http",
+		Checks: []CheckConf{{Type: CheckTypeTTL}, {Type: CheckTypeGrpc}},
+	}
+	client, err := NewService("127.0.0.1:6203", conf)
+	require.NoError(t, err)
+	cc := client.(*CommonClient)
+
+	require.NoError(t, cc.setRegisterServiceHealthStatus(api.HealthCritical))
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, registered, 2)
+	assert.Equal(t, "svc-127.0.0.1-6203:ttl", registered[0].ID)
+	assert.Equal(t, "svc-127.0.0.1-6203", registered[0].ServiceID)
+	assert.Equal(t, api.HealthCritical, registered[0].Status)
+	assert.Equal(t, "20s", registered[0].TTL)
+	assert.Equal(t, "svc-127.0.0.1-6203:grpc", registered[1].ID)
+	assert.Equal(t, "127.0.0.1:6203", registered[1].GRPC)
+}
+
+func TestFilterChecks(t *testing.T) {
+	checks := api.HealthChecks{
+		{CheckID: "a", Status: api.HealthPassing},
+		{CheckID: "b", Status: api.HealthCritical},
+	}
+
+	assert.Equal(t, api.HealthCritical, filterChecks(checks, nil).AggregatedStatus())
+	assert.Equal(t, api.HealthPassing, filterChecks(checks, []string{"a"}).AggregatedStatus())
+}
+
+func TestRegisterServiceMonitors_MultipleChecks(t *testing.T) {
+	srv := fakeConsulServer(t)
+	defer srv.Close()
+
+	conf := Conf{
+		Host: srv.Listener.Addr().String(), Key: "svc", CheckType: CheckTypeTTL,
+		TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http",
+		Checks: []CheckConf{{Type: CheckTypeTTL}, {Type: CheckTypeTcp}},
+	}
+	client, err := NewService("127.0.0.1:6204", conf)
+	require.NoError(t, err)
+	cc := client.(*CommonClient)
+
+	require.NoError(t, cc.registerServiceMonitors())
+	cc.stopAllMonitors()
+
+	// a TTL monitor for the ttl check and an HTTP monitor for the tcp check
+	assert.Len(t, cc.monitorFuncs, 2)
+}
+
+// checksConsulServer returns a fake Consul reporting the health checks of
+// serviceID returned by checks, counting health queries and registrations.
+func checksConsulServer(t *testing.T, serviceID string, checks func() api.HealthChecks,
+	queries, registers *atomic.Int32) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/agent/service/register", func(w http.ResponseWriter, r *http.Request) {
+		registers.Add(1)
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/v1/agent/service/deregister/", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux.HandleFunc("/v1/agent/check/register", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux.HandleFunc("/v1/agent/service/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"ID": serviceID, "Service": "svc"})
+	})
+	mux.HandleFunc("/v1/health/service/", func(w http.ResponseWriter, r *http.Request) {
+		queries.Add(1)
+		entries := []*api.ServiceEntry{
+			{Service: &api.AgentService{ID: serviceID, Service: "svc"}, Checks: checks()},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestHttpMonitorLogic_KeepsConsulStatus(t *testing.T) {
+	var queries, registers atomic.Int32
+	var missing atomic.Bool
+	srv := checksConsulServer(t, "svc-127.0.0.1-6205", func() api.HealthChecks {
+		if missing.Load() {
+			return api.HealthChecks{{CheckID: "svc-127.0.0.1-6205:ttl", Status: api.HealthPassing}}
+		}
+		return api.HealthChecks{
+			{CheckID: "svc-127.0.0.1-6205:ttl", Status: api.HealthPassing},
+			{CheckID: "svc-127.0.0.1-6205:http", Status: api.HealthCritical},
+		}
+	}, &queries, &registers)
+	defer srv.Close()
+
+	conf := Conf{
+		Host: srv.Listener.Addr().String(), Key: "svc", TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http",
+		Checks: []CheckConf{{Type: CheckTypeTTL}, {Type: CheckTypeHttp}},
+	}
+	client, err := NewService("127.0.0.1:6205", conf)
+	require.NoError(t, err)
+	cc := client.(*CommonClient)
+	registers.Store(0)
+
+	state := &MonitorState{
+		BackoffTime: 1 * time.Second, MaxRetries: 5, MaxBackoffTime: 30 * time.Second,
+		OriginalTTL: 5 * time.Second,
+		CheckID:     "svc-127.0.0.1-6205:http",
+		Ticker:      time.NewTicker(time.Hour),
+	}
+	defer state.Close()
+
+	// a failing check run by Consul is left as is
+	require.NoError(t, HttpMonitorLogic(cc, state))
+	assert.Zero(t, registers.Load())
+	assert.Zero(t, state.RetryCount)
+
+	// a missing check is re-registered
+	missing.Store(true)
+	require.NoError(t, HttpMonitorLogic(cc, state))
+	assert.Equal(t, int32(1), registers.Load())
+}
+
+func TestHttpCheckMonitorFunc_CheckInterval(t *testing.T) {
+	var queries, registers atomic.Int32
+	srv := checksConsulServer(t, "svc-127.0.0.1-6206", func() api.HealthChecks {
+		return api.HealthChecks{
+			{CheckID: "svc-127.0.0.1-6206:http", Status: api.HealthPassing},
+			{CheckID: "svc-127.0.0.1-6206:tcp", Status: api.HealthPassing},
+		}
+	}, &queries, &registers)
+	defer srv.Close()
+
+	conf := Conf{
+		Host: srv.Listener.Addr().String(), Key: "svc", TTL: 20, ExpiredTTL: 3, CheckTimeout: 1, Scheme: "http",
+		Checks: []CheckConf{{Type: CheckTypeHttp, Interval: 1}, {Type: CheckTypeTcp, Interval: 1}},
+	}
+	client, err := NewService("127.0.0.1:6206", conf)
+	require.NoError(t, err)
+	cc := client.(*CommonClient)
+	queries.Store(0)
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		HttpCheckMonitorFunc()(cc, stopCh)
+	}()
+
+	// both checks are watched every second, not every TTL - 1 seconds
+	time.Sleep(1500 * time.Millisecond)
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("monitor did not stop")
+	}
+	assert.GreaterOrEqual(t, queries.Load(), int32(2))
+}
+
+// ─────────────────────────────────────────────
+// health.go – built-in health server
+// ─────────────────────────────────────────────
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestValidate_HealthServerDefaults(t *testing.T) {
+	c := Conf{Host: "h:8500", Key: "k", HealthServer: HealthServerConf{Enabled: true}}
+	require.NoError(t, c.Validate())
+	assert.Equal(t, HealthServerConf{Enabled: true, Host: allEths, Port: healthPort, Path: healthPath}, c.HealthServer)
+}
+
+func TestHealthServer_Handle(t *testing.T) {
+	var redisDown atomic.Bool
+	redisDown.Store(true)
+	hs := newHealthServer(HealthServerConf{Path: healthPath}, time.Second, []namedProbe{
+		{name: "db", probe: func(ctx context.Context) error { return nil }},
+		{name: "redis", probe: func(ctx context.Context) error {
+			if redisDown.Load() {
+				return errors.New("dial tcp: connection refused")
+			}
+			return nil
+		}},
+	})
+
+	get := func() (int, healthResponse) {
+		rec := httptest.NewRecorder()
+		hs.handle(rec, httptest.NewRequest(http.MethodGet, healthPath, nil))
+		var resp healthResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec.Code, resp
+	}
+
+	code, resp := get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, healthStatusUnavailable, resp.Status)
+	assert.Equal(t, healthStatusOk, resp.Probes["db"])
+	assert.Equal(t, "dial tcp: connection refused", resp.Probes["redis"])
+
+	redisDown.Store(false)
+	code, resp = get()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, healthStatusOk, resp.Status)
+
+	hs.markShuttingDown()
+	code, resp = get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, healthStatusShuttingDown, resp.Status)
+}
+
+func TestHealthServer_ProbeTimeoutAndPanic(t *testing.T) {
+	hs := newHealthServer(HealthServerConf{Path: healthPath}, 50*time.Millisecond, []namedProbe{
+		{name: "slow", probe: func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}},
+		{name: "panic", probe: func(ctx context.Context) error { panic("boom") }},
+	})
+
+	resp := hs.check(context.Background())
+	assert.Equal(t, healthStatusUnavailable, resp.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), resp.Probes["slow"])
+	assert.Contains(t, resp.Probes["panic"], "boom")
+}
+
+func TestRegisterService_HealthServer(t *testing.T) {
+	var lock sync.Mutex
+	var statuses []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/agent/service/register", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux.HandleFunc("/v1/agent/service/deregister/", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		statuses = append(statuses, "deregistered")
+		lock.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/v1/agent/check/register", func(w http.ResponseWriter, r *http.Request) {
+		var reg api.AgentCheckRegistration
+		_ = json.NewDecoder(r.Body).Decode(&reg)
+		lock.Lock()
+		statuses = append(statuses, reg.Status)
+		lock.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	port := freePort(t)
+	conf := Conf{
+		Host: srv.Listener.Addr().String(), Key: "svc", CheckType: CheckTypeTTL,
+		TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http",
+		HealthServer: HealthServerConf{Enabled: true, Host: "127.0.0.1", Port: port},
+	}
+	client, err := NewService("127.0.0.1:6205", conf, WithReadinessProbe("db", func(ctx context.Context) error {
+		return nil
+	}))
+	require.NoError(t, err)
+	require.NoError(t, client.RegisterService())
+
+	healthURL := fmt.Sprintf("http://127.0.0.1:%d%s", port, healthPath)
+	resp, err := http.Get(healthURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, client.DeregisterService())
+
+	// set critical before deregistration
+	lock.Lock()
+	require.GreaterOrEqual(t, len(statuses), 2)
+	assert.Equal(t, []string{api.HealthCritical, "deregistered"}, statuses[len(statuses)-2:])
+	lock.Unlock()
+
+	// the health server is stopped after deregistration
+	_, err = http.Get(healthURL)
+	assert.Error(t, err)
+}
+
+func TestRegisterService_HealthServerListenFails(t *testing.T) {
+	srv := fakeConsulServer(t)
+	defer srv.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	conf := Conf{
+		Host: srv.Listener.Addr().String(), Key: "svc", CheckType: CheckTypeTTL,
+		TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http",
+		HealthServer: HealthServerConf{Enabled: true, Host: "127.0.0.1", Port: l.Addr().(*net.TCPAddr).Port},
+	}
+	client, err := NewService("127.0.0.1:6206", conf)
+	require.NoError(t, err)
+	require.Error(t, client.RegisterService())
+}
+
+// ─────────────────────────────────────────────
+// maintenance.go – drain and maintenance mode
+// ─────────────────────────────────────────────
+
+// drainConsulServer records the calls of the service lifecycle in order.
+func drainConsulServer(t *testing.T, maintenanceCode int) (*httptest.Server, func() []string) {
+	t.Helper()
+	var lock sync.Mutex
+	var calls []string
+	record := func(call string) {
+		lock.Lock()
+		calls = append(calls, call)
+		lock.Unlock()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/agent/service/register", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux.HandleFunc("/v1/agent/service/deregister/", func(w http.ResponseWriter, r *http.Request) {
+		record("deregister")
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/v1/agent/service/maintenance/", func(w http.ResponseWriter, r *http.Request) {
+		record("maintenance=" + r.URL.Query().Get("enable") + ":" + r.URL.Query().Get("reason"))
+		w.WriteHeader(maintenanceCode)
+	})
+	mux.HandleFunc("/v1/agent/check/register", func(w http.ResponseWriter, r *http.Request) {
+		var reg api.AgentCheckRegistration
+		_ = json.NewDecoder(r.Body).Decode(&reg)
+		record("check=" + reg.Status)
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := httptest.NewServer(mux)
+
+	return srv, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string(nil), calls...)
+	}
+}
+
+func TestEnableDisableMaintenance(t *testing.T) {
+	srv, calls := drainConsulServer(t, http.StatusOK)
+	defer srv.Close()
+
+	conf := Conf{Host: srv.Listener.Addr().String(), Key: "svc", CheckType: CheckTypeTTL, TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http"}
+	client, err := NewService("127.0.0.1:6207", conf)
+	require.NoError(t, err)
+
+	require.NoError(t, client.EnableMaintenance("upgrade"))
+	require.NoError(t, client.DisableMaintenance())
+	assert.Equal(t, []string{"maintenance=true:upgrade", "maintenance=false:"}, calls())
+}
+
+func TestDrain_Maintenance(t *testing.T) {
+	srv, calls := drainConsulServer(t, http.StatusOK)
+	defer srv.Close()
+
+	conf := Conf{Host: srv.Listener.Addr().String(), Key: "svc", CheckType: CheckTypeTTL, TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http"}
+	client, err := NewService("127.0.0.1:6208", conf)
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, client.Drain(context.Background(), 100*time.Millisecond))
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Equal(t, []string{"maintenance=true:" + drainReason, "deregister"}, calls())
+}
+
+func TestDrain_WarningFallback(t *testing.T) {
+	srv, calls := drainConsulServer(t, http.StatusForbidden)
+	defer srv.Close()
+
+	conf := Conf{Host: srv.Listener.Addr().String(), Key: "svc", CheckType: CheckTypeTTL, TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http"}
+	client, err := NewService("127.0.0.1:6209", conf)
+	require.NoError(t, err)
+
+	require.NoError(t, client.Drain(context.Background(), 0))
+	assert.Equal(t, []string{"maintenance=true:" + drainReason, "check=" + api.HealthWarning, "deregister"}, calls())
+}
+
+func TestDrain_ContextCutsGraceShort(t *testing.T) {
+	srv, calls := drainConsulServer(t, http.StatusOK)
+	defer srv.Close()
+
+	conf := Conf{Host: srv.Listener.Addr().String(), Key: "svc", CheckType: CheckTypeTTL, TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http"}
+	client, err := NewService("127.0.0.1:6210", conf)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.NoError(t, client.Drain(ctx, time.Minute))
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Contains(t, calls(), "deregister")
+}
+
+func TestValidate_NegativeDrainGrace(t *testing.T) {
+	c := Conf{Host: "h:8500", Key: "k", DrainGrace: -1}
+	require.Error(t, c.Validate())
+}
+
+// ─────────────────────────────────────────────
+// discov.go – go-zero discov compatible publisher and subscriber
+// ─────────────────────────────────────────────
+
+func TestSubscriber_Values(t *testing.T) {
+	svc := &mockServicer{
+		entries: serviceEntries("10.0.0.2:8080", "10.0.0.1:8080", "10.0.0.1:8080"),
+		meta:    &api.QueryMeta{LastIndex: 1},
+	}
+	sub := newSubscriber(svc, target{Service: "test", MaxBackoff: time.Second})
+	defer sub.Close()
+
+	select {
+	case <-sub.ready:
+	case <-time.After(3 * time.Second):
+		t.Fatal("no addresses")
+	}
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, sub.Values())
+}
+
+func TestSubscriber_AllGone(t *testing.T) {
+	svc := &seqServicer{responses: [][]*api.ServiceEntry{
+		serviceEntries("10.0.0.1:8080"),
+		{},
+	}}
+	sub := newSubscriber(svc, target{Service: "test", MaxBackoff: time.Second})
+	defer sub.Close()
+
+	select {
+	case <-sub.ready:
+	case <-time.After(3 * time.Second):
+		t.Fatal("no addresses")
+	}
+	// unlike the resolver, the last addresses are not kept once every instance is gone
+	assert.Eventually(t, func() bool {
+		return len(sub.Values()) == 0
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestSubscriber_Listener(t *testing.T) {
+	sub := &Subscriber{ready: make(chan struct{})}
+	var called int32
+	sub.AddListener(func() {
+		atomic.AddInt32(&called, 1)
+	})
+
+	sub.update([]*consulAddr{{Addr: "10.0.0.1", Port: 8080}})
+	assert.Equal(t, int32(1), atomic.LoadInt32(&called))
+
+	// unchanged addresses don't notify
+	sub.update([]*consulAddr{{Addr: "10.0.0.1", Port: 8080}})
+	assert.Equal(t, int32(1), atomic.LoadInt32(&called))
+
+	sub.update([]*consulAddr{{Addr: "10.0.0.1", Port: 8080}, {Addr: "10.0.0.2", Port: 8080}})
+	assert.Equal(t, int32(2), atomic.LoadInt32(&called))
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, sub.Values())
+}
+
+func TestNewSubscriber(t *testing.T) {
+	var query url.Values
+	var lock sync.Mutex
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/health/service/svc", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		query = r.URL.Query()
+		lock.Unlock()
+		w.Header().Set("X-Consul-Index", "1")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(serviceEntries("10.0.0.1:8080"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	sub, err := NewSubscriber([]string{srv.Listener.Addr().String()}, "svc", WithSubParam("tag", "v1"))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	assert.Equal(t, []string{"10.0.0.1:8080"}, sub.Values())
+	lock.Lock()
+	assert.Equal(t, "1", query.Get("passing"), "paused instances are hidden by default")
+	assert.Equal(t, "v1", query.Get("tag"))
+	lock.Unlock()
+
+	all, err := NewSubscriber([]string{srv.Listener.Addr().String()}, "svc", WithSubUnhealthy())
+	require.NoError(t, err)
+	defer all.Close()
+	assert.Equal(t, "false", all.params.Get("healthy"))
+}
+
+func TestNewSubscriber_Errors(t *testing.T) {
+	_, err := NewSubscriber(nil, "svc")
+	require.Error(t, err)
+	_, err = NewSubscriber([]string{"localhost:8500"}, "svc", WithSubParam("limit", "x"))
+	require.Error(t, err)
+}
+
+func TestPublisher(t *testing.T) {
+	srv, calls := drainConsulServer(t, http.StatusOK)
+	defer srv.Close()
+
+	conf := Conf{CheckType: CheckTypeTTL, TTL: 20, ExpiredTTL: 3, CheckTimeout: 3, Scheme: "http"}
+	called := make(chan struct{}, 1)
+	pub := NewPublisher([]string{srv.Listener.Addr().String()}, "svc", "127.0.0.1:6211",
+		WithPubConf(conf), WithPubServiceOptions(WithMonitorFuncs(func(cc *CommonClient, stopCh <-chan struct{}) {
+			called <- struct{}{}
+			<-stopCh
+		})))
+
+	// no-ops before KeepAlive
+	pub.Pause()
+	pub.Resume()
+	assert.Empty(t, calls())
+
+	require.NoError(t, pub.KeepAlive())
+	require.NoError(t, pub.KeepAlive())
+	select {
+	case <-called:
+	case <-time.After(3 * time.Second):
+		t.Fatal("monitor not started")
+	}
+
+	pub.Pause()
+	pub.Resume()
+	pub.Stop()
+	pub.Stop()
+
+	got := calls()
+	require.GreaterOrEqual(t, len(got), 3)
+	assert.Equal(t, []string{"maintenance=true:" + pauseReason, "maintenance=false:", "check=" + api.HealthCritical, "deregister"},
+		got[len(got)-4:])
+}
+
+func TestPublisher_KeepAliveInvalidConf(t *testing.T) {
+	pub := NewPublisher(nil, "svc", "127.0.0.1:6212")
+	require.Error(t, pub.KeepAlive())
+}
+
+// ─────────────────────────────────────────────
+// resovler.go – cache-on-failure
+// ─────────────────────────────────────────────
+
+// seqServicer returns its responses in order, repeating the last one.
+type seqServicer struct {
+	mu        sync.Mutex
+	calls     int
+	responses [][]*api.ServiceEntry
+	errs      []error
+}
+
+func (m *seqServicer) Service(service, tag string, passingOnly bool, q *api.QueryOptions) ([]*api.ServiceEntry, *api.QueryMeta, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.calls
+	m.calls++
+	if i >= len(m.responses) {
+		// block like a query without changes
+		m.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		m.mu.Lock()
+		i = len(m.responses) - 1
+	}
+	if i < len(m.errs) && m.errs[i] != nil {
+		return nil, nil, m.errs[i]
+	}
+	return m.responses[i], &api.QueryMeta{LastIndex: uint64(i + 1)}, nil
+}
+
+func collectEndpoints(t *testing.T, svc servicer, tgt target, n int) [][]*consulAddr {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := make(chan []*consulAddr)
+	go watchConsulService(ctx, svc, tgt, out)
+
+	var got [][]*consulAddr
+	timeout := time.After(300 * time.Millisecond)
+	for len(got) < n {
+		select {
+		case ee := <-out:
+			got = append(got, ee)
+		case <-timeout:
+			return got
+		}
+	}
+	return got
+}
+
+func TestWatchConsulService_KeepsEndpointsOnEmpty(t *testing.T) {
+	svc := &seqServicer{responses: [][]*api.ServiceEntry{
+		serviceEntries("10.0.0.1:8080"),
+		nil,
+		nil,
+	}}
+	got := collectEndpoints(t, svc, target{Service: "test", MaxBackoff: time.Millisecond}, 100)
+
+	// the empty sets are skipped, only the repeated last response is served again
+	require.NotEmpty(t, got)
+	for _, ee := range got {
+		assert.Len(t, ee, 1)
+	}
+}
+
+func TestWatchConsulService_AllowEmpty(t *testing.T) {
+	svc := &seqServicer{responses: [][]*api.ServiceEntry{
+		serviceEntries("10.0.0.1:8080"),
+		nil,
+	}}
+	got := collectEndpoints(t, svc, target{Service: "test", MaxBackoff: time.Millisecond, AllowEmpty: true}, 2)
+
+	require.Len(t, got, 2)
+	assert.Len(t, got[0], 1)
+	assert.Empty(t, got[1])
+}
+
+func TestWatchConsulService_FirstEmptyServed(t *testing.T) {
+	svc := &seqServicer{responses: [][]*api.ServiceEntry{nil, serviceEntries("10.0.0.1:8080")}}
+	got := collectEndpoints(t, svc, target{Service: "test", MaxBackoff: time.Millisecond}, 2)
+
+	require.Len(t, got, 2)
+	assert.Empty(t, got[0])
+	assert.Len(t, got[1], 1)
+}
+
+func TestWatchConsulService_KeepsEndpointsOnError(t *testing.T) {
+	svc := &seqServicer{
+		responses: [][]*api.ServiceEntry{serviceEntries("10.0.0.1:8080"), nil, serviceEntries("10.0.0.2:8080")},
+		errs:      []error{nil, errors.New("partition")},
+	}
+	got := collectEndpoints(t, svc, target{Service: "test", MaxBackoff: time.Millisecond}, 2)
+
+	require.Len(t, got, 2)
+	assert.Equal(t, "10.0.0.1", got[0][0].Addr)
+	assert.Equal(t, "10.0.0.2", got[1][0].Addr)
+}
+
+func TestParseURL_AllowEmpty(t *testing.T) {
+	tgt, err := parseURL(mustParseURL("consul://localhost:8500/svc?allow-empty=true"))
+	require.NoError(t, err)
+	assert.True(t, tgt.AllowEmpty)
+}
@@ -0,0 +1,288 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const (
+	pauseReason      = "paused by publisher"
+	subscribeTimeout = 3 * time.Second
+)
+
+type (
+	// Publisher registers a service in Consul, with the same shape as go-zero
+	// discov.Publisher, so that code written around etcd keeps working.
+	Publisher struct {
+		endpoints   []string
+		key         string
+		value       string
+		conf        Conf
+		serviceOpts []ServiceOption
+		lock        sync.Mutex
+		client      Client
+	}
+
+	// PubOption is the function signature for Publisher options.
+	PubOption func(*Publisher)
+
+	// Subscriber watches the addresses of a service in Consul, with the same shape
+	// as go-zero discov.Subscriber, so that code written around etcd keeps working.
+	Subscriber struct {
+		cancel    context.CancelFunc
+		lock      sync.RWMutex
+		values    []string
+		listeners []func()
+		ready     chan struct{}
+		readyOnce sync.Once
+		params    url.Values
+	}
+
+	// SubOption is the function signature for Subscriber options.
+	SubOption func(*Subscriber)
+)
+
+// NewPublisher returns a Publisher of key, the service name, at value, its listen address.
+// endpoints are the Consul hosts, only the first one is used, e.g. the local agent.
+func NewPublisher(endpoints []string, key, value string, opts ...PubOption) *Publisher {
+	p := &Publisher{
+		endpoints: endpoints,
+		key:       key,
+		value:     value,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// KeepAlive registers the service, keeps it alive with its health checks
+// and deregisters it on shutdown.
+func (p *Publisher) KeepAlive() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.client != nil {
+		return nil
+	}
+
+	c := p.conf
+	if len(p.endpoints) > 0 {
+		c.Host = p.endpoints[0]
+	}
+	c.Key = p.key
+	client, err := NewService(p.value, c, p.serviceOpts...)
+	if err != nil {
+		return err
+	}
+	if err = client.RegisterService(); err != nil {
+		return err
+	}
+	p.client = client
+
+	return nil
+}
+
+// Pause puts the service into maintenance mode, Subscribers and healthy-only
+// resolvers don't discover it until resumed.
+func (p *Publisher) Pause() {
+	p.withClient(func(client Client) {
+		if err := client.EnableMaintenance(pauseReason); err != nil {
+			logx.Errorf("pause service %s error: %v", client.GetServiceID(), err)
+		}
+	})
+}
+
+// Resume takes the service out of maintenance mode.
+func (p *Publisher) Resume() {
+	p.withClient(func(client Client) {
+		if err := client.DisableMaintenance(); err != nil {
+			logx.Errorf("resume service %s error: %v", client.GetServiceID(), err)
+		}
+	})
+}
+
+// Stop deregisters the service.
+func (p *Publisher) Stop() {
+	p.lock.Lock()
+	client := p.client
+	p.client = nil
+	p.lock.Unlock()
+
+	if client == nil {
+		return
+	}
+	if err := client.DeregisterService(); err != nil {
+		logx.Errorf("deregister service %s error: %v", client.GetServiceID(), err)
+	}
+}
+
+func (p *Publisher) withClient(fn func(client Client)) {
+	p.lock.Lock()
+	client := p.client
+	p.lock.Unlock()
+
+	if client != nil {
+		fn(client)
+	}
+}
+
+// WithPubConf sets the registration config, its Host and Key are replaced by
+// the endpoints and key of the Publisher.
+func WithPubConf(c Conf) PubOption {
+	return func(p *Publisher) {
+		p.conf = c
+	}
+}
+
+// WithPubServiceOptions sets the options of the registered service, e.g. WithMonitorFuncs.
+func WithPubServiceOptions(opts ...ServiceOption) PubOption {
+	return func(p *Publisher) {
+		p.serviceOpts = append(p.serviceOpts, opts...)
+	}
+}
+
+// NewSubscriber returns a Subscriber of the addresses of key, the service name.
+// endpoints are the Consul hosts, only the first one is used, e.g. the local agent.
+// Only the instances passing their health checks are watched, so that a paused
+// Publisher disappears like in go-zero discov. It waits a few seconds for the first addresses.
+func NewSubscriber(endpoints []string, key string, opts ...SubOption) (*Subscriber, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("empty consul hosts")
+	}
+
+	sub := &Subscriber{params: url.Values{"healthy": []string{"true"}}}
+	for _, opt := range opts {
+		opt(sub)
+	}
+
+	tgt, err := parseURL(url.URL{
+		Scheme:   schemeName,
+		Host:     endpoints[0],
+		Path:     "/" + key,
+		RawQuery: sub.params.Encode(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	cli, err := api.NewClient(tgt.consulConfig())
+	if err != nil {
+		return nil, fmt.Errorf("couldn't connect to the Consul API: %w", err)
+	}
+
+	sub.watch(cli.Health(), tgt)
+	select {
+	case <-sub.ready:
+	case <-time.After(subscribeTimeout):
+		logx.Errorf("[Consul subscriber] No addresses of target={%s} yet", tgt.String())
+	}
+
+	return sub, nil
+}
+
+func newSubscriber(s servicer, tgt target) *Subscriber {
+	sub := &Subscriber{}
+	sub.watch(s, tgt)
+	return sub
+}
+
+// AddListener adds listener, called when the addresses change.
+func (s *Subscriber) AddListener(listener func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.listeners = append(s.listeners, listener)
+}
+
+// Close stops watching the service.
+func (s *Subscriber) Close() {
+	s.cancel()
+}
+
+// Values returns the addresses of the service in host:port format.
+func (s *Subscriber) Values() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return append([]string(nil), s.values...)
+}
+
+func (s *Subscriber) watch(sv servicer, tgt target) {
+	// like go-zero discov, Values reflects that every instance is gone
+	tgt.AllowEmpty = true
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.ready = make(chan struct{})
+
+	pipe := make(chan []*consulAddr)
+	go watchConsulService(ctx, sv, tgt, pipe)
+	go func() {
+		for {
+			select {
+			case addrs := <-pipe:
+				s.update(addrs)
+			case <-ctx.Done():
+				logx.Info("[Consul subscriber] Watch has been finished")
+				return
+			}
+		}
+	}()
+}
+
+// update replaces the addresses, notifying the listeners if they changed.
+func (s *Subscriber) update(addrs []*consulAddr) {
+	values := make([]string, 0, len(addrs))
+	seen := make(map[string]bool, len(addrs))
+	for _, a := range addrs {
+		addr := fmt.Sprintf("%s:%d", a.Addr, a.Port)
+		if !seen[addr] {
+			seen[addr] = true
+			values = append(values, addr)
+		}
+	}
+	sort.Strings(values)
+
+	s.lock.Lock()
+	changed := !slices.Equal(s.values, values)
+	s.values = values
+	listeners := append([]func(){}, s.listeners...)
+	s.lock.Unlock()
+
+	s.readyOnce.Do(func() {
+		close(s.ready)
+	})
+	if !changed {
+		return
+	}
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+// WithSubUnhealthy also watches the instances failing their health checks or in
+// maintenance mode, e.g. paused by a Publisher.
+func WithSubUnhealthy() SubOption {
+	return WithSubParam("healthy", "false")
+}
+
+// WithSubToken sets the Consul ACL token.
+func WithSubToken(token string) SubOption {
+	return WithSubParam("token", token)
+}
+
+// WithSubParam sets a parameter of the consul URL, e.g. tag or dc, see the resolver.
+func WithSubParam(name, value string) SubOption {
+	return func(s *Subscriber) {
+		s.params.Set(name, value)
+	}
+}
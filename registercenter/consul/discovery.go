@@ -0,0 +1,228 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// ErrNoEndpoints is returned by Discovery.Pick when the service has no endpoints.
+var ErrNoEndpoints = errors.New("no endpoints available")
+
+type (
+	// Discovery watches the endpoints of a service for non-gRPC callers,
+	// picking an endpoint by power of two choices (P2C) on the in-flight requests
+	// and latency of the endpoints.
+	Discovery struct {
+		tgt       target
+		cancel    context.CancelFunc
+		lock      sync.RWMutex
+		endpoints []*endpoint
+		ready     chan struct{}
+		readyOnce sync.Once
+	}
+
+	endpoint struct {
+		addr     string
+		inflight int64
+		// lag is the moving average of the latency in nanoseconds
+		lag int64
+	}
+)
+
+// MustNewDiscovery returns a Discovery, exits on errors.
+func MustNewDiscovery(rawURL string) *Discovery {
+	d, err := NewDiscovery(rawURL)
+	logx.Must(err)
+	return d
+}
+
+// NewDiscovery returns a Discovery of the service of rawURL, with the same format and
+// parameters as the gRPC resolver, e.g. consul://127.0.0.1:8500/user-api?healthy=true
+func NewDiscovery(rawURL string) (*Discovery, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("wrong consul URL: %w", err)
+	}
+	tgt, err := parseURL(*u)
+	if err != nil {
+		return nil, fmt.Errorf("wrong consul URL: %w", err)
+	}
+	cli, err := api.NewClient(tgt.consulConfig())
+	if err != nil {
+		return nil, fmt.Errorf("couldn't connect to the Consul API: %w", err)
+	}
+
+	return newDiscovery(cli.Health(), tgt), nil
+}
+
+func newDiscovery(s servicer, tgt target) *Discovery {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Discovery{
+		tgt:    tgt,
+		cancel: cancel,
+		ready:  make(chan struct{}),
+	}
+	pipe := make(chan []*consulAddr)
+	go watchConsulService(ctx, s, tgt, pipe)
+	go d.populate(ctx, pipe)
+
+	return d
+}
+
+// Close stops watching the service.
+func (d *Discovery) Close() {
+	d.cancel()
+}
+
+// Endpoints returns the current endpoints of the service, in host:port format.
+func (d *Discovery) Endpoints() []string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	addrs := make([]string, 0, len(d.endpoints))
+	for _, e := range d.endpoints {
+		addrs = append(addrs, e.addr)
+	}
+
+	return addrs
+}
+
+// Pick returns the address of an endpoint in host:port format, ErrNoEndpoints if none.
+func (d *Discovery) Pick() (string, error) {
+	e, err := d.pick(nil)
+	if err != nil {
+		return "", err
+	}
+
+	return e.addr, nil
+}
+
+// Wait waits until the endpoints of the service are fetched for the first time.
+func (d *Discovery) Wait(ctx context.Context) error {
+	select {
+	case <-d.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pick picks an endpoint by P2C, skipping the endpoints of excluded if any other is left.
+func (d *Discovery) pick(excluded map[string]bool) (*endpoint, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	candidates := d.endpoints
+	if len(excluded) > 0 {
+		candidates = make([]*endpoint, 0, len(d.endpoints))
+		for _, e := range d.endpoints {
+			if !excluded[e.addr] {
+				candidates = append(candidates, e)
+			}
+		}
+		if len(candidates) == 0 {
+			candidates = d.endpoints
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("%w for target={%s}", ErrNoEndpoints, d.tgt.String())
+	case 1:
+		return candidates[0], nil
+	}
+
+	a := rand.Intn(len(candidates))
+	b := rand.Intn(len(candidates) - 1)
+	if b >= a {
+		b++
+	}
+	if candidates[b].load() < candidates[a].load() {
+		return candidates[b], nil
+	}
+
+	return candidates[a], nil
+}
+
+func (d *Discovery) populate(ctx context.Context, input <-chan []*consulAddr) {
+	for {
+		select {
+		case addrs := <-input:
+			d.update(addrs)
+		case <-ctx.Done():
+			logx.Info("[Consul discovery] Watch has been finished")
+			return
+		}
+	}
+}
+
+// update replaces the endpoints, keeping the stats of the endpoints still present.
+func (d *Discovery) update(addrs []*consulAddr) {
+	d.lock.Lock()
+	existing := make(map[string]*endpoint, len(d.endpoints))
+	for _, e := range d.endpoints {
+		existing[e.addr] = e
+	}
+
+	endpoints := make([]*endpoint, 0, len(addrs))
+	seen := make(map[string]bool, len(addrs))
+	for _, a := range addrs {
+		addr := fmt.Sprintf("%s:%d", a.Addr, a.Port)
+		if seen[addr] {
+			continue
+		}
+		seen[addr] = true
+
+		if e, ok := existing[addr]; ok {
+			endpoints = append(endpoints, e)
+		} else {
+			endpoints = append(endpoints, &endpoint{addr: addr})
+		}
+	}
+	d.endpoints = endpoints
+	d.lock.Unlock()
+
+	d.readyOnce.Do(func() {
+		close(d.ready)
+	})
+}
+
+// start marks a request to e as in flight, the returned func ends it.
+func (e *endpoint) start() func() {
+	atomic.AddInt64(&e.inflight, 1)
+	begin := time.Now()
+
+	return func() {
+		atomic.AddInt64(&e.inflight, -1)
+		rtt := int64(time.Since(begin))
+		for {
+			lag := atomic.LoadInt64(&e.lag)
+			next := rtt
+			if lag > 0 {
+				next = (lag*7 + rtt*3) / 10
+			}
+			if atomic.CompareAndSwapInt64(&e.lag, lag, next) {
+				return
+			}
+		}
+	}
+}
+
+// load is the in-flight requests, weighted by the latency.
+func (e *endpoint) load() int64 {
+	lag := atomic.LoadInt64(&e.lag)
+	if lag <= 0 {
+		lag = 1
+	}
+
+	return (atomic.LoadInt64(&e.inflight) + 1) * lag
+}
@@ -0,0 +1,173 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const (
+	healthStatusOk           = "ok"
+	healthStatusUnavailable  = "unavailable"
+	healthStatusShuttingDown = "shutting down"
+	healthShutdownTimeout    = 5 * time.Second
+)
+
+type (
+	// ReadinessProbe checks a dependency of the service, e.g. a DB or Redis ping.
+	// The service is not ready if it returns an error.
+	ReadinessProbe func(ctx context.Context) error
+
+	namedProbe struct {
+		name  string
+		probe ReadinessProbe
+	}
+
+	// healthServer serves the readiness of the service, aggregated from the probes.
+	healthServer struct {
+		conf         HealthServerConf
+		timeout      time.Duration
+		probes       []namedProbe
+		server       *http.Server
+		shuttingDown atomic.Bool
+	}
+
+	healthResponse struct {
+		Status string            `json:"status"`
+		Probes map[string]string `json:"probes,omitempty"`
+	}
+)
+
+func newHealthServer(c HealthServerConf, timeout time.Duration, probes []namedProbe) *healthServer {
+	return &healthServer{
+		conf:    c,
+		timeout: timeout,
+		probes:  probes,
+	}
+}
+
+// start listens on the configured address and serves in the background.
+func (hs *healthServer) start() error {
+	addr := fmt.Sprintf("%s:%d", hs.conf.Host, hs.conf.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("health server listen on %s error: %v", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(hs.conf.Path, hs.handle)
+	hs.server = &http.Server{Handler: mux}
+	hs.shuttingDown.Store(false)
+
+	go func() {
+		if err := hs.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logx.Errorf("health server on %s error: %v", addr, err)
+		}
+	}()
+	logx.Infof("Health server listening on %s%s", addr, hs.conf.Path)
+
+	return nil
+}
+
+// markShuttingDown makes the health server report 503 from now on.
+func (hs *healthServer) markShuttingDown() {
+	hs.shuttingDown.Store(true)
+}
+
+// stop stops the health server.
+func (hs *healthServer) stop() error {
+	if hs.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthShutdownTimeout)
+	defer cancel()
+
+	return hs.server.Shutdown(ctx)
+}
+
+func (hs *healthServer) handle(w http.ResponseWriter, r *http.Request) {
+	resp := hs.check(r.Context())
+	code := http.StatusOK
+	if resp.Status != healthStatusOk {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// check runs all probes concurrently, the service is ready if all of them pass.
+func (hs *healthServer) check(ctx context.Context) healthResponse {
+	if hs.shuttingDown.Load() {
+		return healthResponse{Status: healthStatusShuttingDown}
+	}
+	if len(hs.probes) == 0 {
+		return healthResponse{Status: healthStatusOk}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hs.timeout)
+	defer cancel()
+
+	errs := make([]error, len(hs.probes))
+	var wg sync.WaitGroup
+	for i, p := range hs.probes {
+		wg.Add(1)
+		go func(i int, p namedProbe) {
+			defer wg.Done()
+			errs[i] = runProbe(ctx, p.probe)
+		}(i, p)
+	}
+	wg.Wait()
+
+	resp := healthResponse{
+		Status: healthStatusOk,
+		Probes: make(map[string]string, len(hs.probes)),
+	}
+	for i, p := range hs.probes {
+		if errs[i] != nil {
+			resp.Status = healthStatusUnavailable
+			resp.Probes[p.name] = errs[i].Error()
+		} else {
+			resp.Probes[p.name] = healthStatusOk
+		}
+	}
+
+	return resp
+}
+
+// runProbe runs probe, failing if it doesn't return before ctx is done.
+func runProbe(ctx context.Context, probe ReadinessProbe) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("probe panic: %v", p)
+			}
+		}()
+		done <- probe(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithReadinessProbe adds a readiness probe to the built-in health server.
+// example: MustNewService(listenOn, c, WithReadinessProbe("redis", func(ctx context.Context) error { ... }))
+func WithReadinessProbe(name string, probe ReadinessProbe) ServiceOption {
+	return func(cc *CommonClient) {
+		cc.probes = append(cc.probes, namedProbe{name: name, probe: probe})
+	}
+}
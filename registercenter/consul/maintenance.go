@@ -0,0 +1,55 @@
+package consul
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const drainReason = "draining before shutdown"
+
+// EnableMaintenance puts the service into Consul maintenance mode, so that it is
+// no longer returned to the healthy-only watchers. The service stays registered.
+func (cc *CommonClient) EnableMaintenance(reason string) error {
+	return cc.apiClient.Agent().EnableServiceMaintenance(cc.serviceId, reason)
+}
+
+// DisableMaintenance takes the service out of Consul maintenance mode.
+func (cc *CommonClient) DisableMaintenance() error {
+	return cc.apiClient.Agent().DisableServiceMaintenance(cc.serviceId)
+}
+
+// Drain takes the service out of traffic before deregistering it. The service is put
+// into maintenance mode, or its checks are set warning if maintenance mode fails, and
+// the watchers are given grace to converge before the deregistration.
+// The grace is cut short if ctx is done, the service is deregistered anyway.
+func (cc *CommonClient) Drain(ctx context.Context, grace time.Duration) error {
+	cc.stopAllMonitors()
+	if cc.health != nil {
+		cc.health.markShuttingDown()
+	}
+
+	if err := cc.EnableMaintenance(drainReason); err != nil {
+		logx.Errorf("enable maintenance of service %s error: %v, setting checks warning", cc.serviceId, err)
+		if err = cc.setRegisterServiceHealthStatus(api.HealthWarning); err != nil {
+			logx.Errorf("set service %s warning error: %v", cc.serviceId, err)
+		}
+	}
+
+	if grace > 0 {
+		logx.Infof("Draining service %s for %v", cc.serviceId, grace)
+		timer := time.NewTimer(grace)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			logx.Infof("Draining service %s cut short: %v", cc.serviceId, ctx.Err())
+		}
+	}
+
+	err := cc.deleteRegisterService()
+	cc.stopHealthServer()
+	return err
+}
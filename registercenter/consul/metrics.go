@@ -0,0 +1,48 @@
+package consul
+
+import "github.com/zeromicro/go-zero/core/metric"
+
+const (
+	metricsNamespace  = "registercenter_consul"
+	resolverSubsystem = "resolver"
+)
+
+// resolver metrics, labeled by the watched service
+var (
+	// endpoints served to the resolver (service)
+	metricResolverEndpoints = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: resolverSubsystem,
+		Name:      "endpoints",
+		Help:      "Consul resolver endpoints served",
+		Labels:    []string{"service"},
+	})
+
+	// failed queries (service)
+	metricResolverWatchErrors = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: resolverSubsystem,
+		Name:      "watch_errors_total",
+		Help:      "Consul resolver failed watch queries",
+		Labels:    []string{"service"},
+	})
+
+	// query duration, including the blocking wait (service)
+	metricResolverWatchDuration = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: resolverSubsystem,
+		Name:      "watch_duration_ms",
+		Help:      "Consul resolver watch query duration(ms), including the blocking wait",
+		Labels:    []string{"service"},
+		Buckets:   []float64{5, 10, 25, 50, 100, 250, 500, 1000, 5000, 30000, 60000, 300000},
+	})
+
+	// last time endpoints were served, the age is time() minus it (service)
+	metricResolverLastUpdate = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: resolverSubsystem,
+		Name:      "last_update_timestamp_seconds",
+		Help:      "Consul resolver last time endpoints were served, in unix seconds",
+		Labels:    []string{"service"},
+	})
+)
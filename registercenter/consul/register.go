@@ -1,6 +1,7 @@
 package consul
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -20,6 +21,9 @@ type (
 	Client interface {
 		RegisterService() error
 		DeregisterService() error
+		Drain(ctx context.Context, grace time.Duration) error
+		EnableMaintenance(reason string) error
+		DisableMaintenance() error
 		GetServiceID() string
 		GetRegistration() *api.AgentServiceRegistration
 		GetServiceClient() *api.Client
@@ -37,6 +41,8 @@ type (
 		monitorMutex   sync.RWMutex
 		stopMonitorChs []chan struct{}
 		stopChMutex    sync.Mutex
+		probes         []namedProbe
+		health         *healthServer
 	}
 
 	// MonitorState holds the state for the health check monitor.
@@ -47,6 +53,7 @@ type (
 		MaxRetries     int
 		Ticker         *time.Ticker
 		OriginalTTL    time.Duration // 保存原始的TTL值，用于重置
+		CheckID        string        // check to monitor, the service ID if empty
 		Mutex          sync.RWMutex
 	}
 
@@ -109,26 +116,41 @@ func NewService(listenOn string, c Conf, opts ...ServiceOption) (Client, error)
 		return nil, err
 	}
 
+	if c.HealthServer.Enabled {
+		timeout := time.Duration(c.CheckTimeout) * time.Second
+		service.health = newHealthServer(c.HealthServer, timeout, service.probes)
+	}
+
 	return service, nil
 }
 
 // RegisterService registers the service with Consul.
 // It returns an error if the registration fails.
 // The service is registered with a passing health check.
+// The built-in health server is started first if enabled.
+// The service is drained for Conf.DrainGrace and deregistered on shutdown.
 func (cc *CommonClient) RegisterService() error {
+	if cc.health != nil {
+		if err := cc.health.start(); err != nil {
+			return err
+		}
+	}
+
 	err := cc.registerServiceWithPassingHealth()
 	if err != nil {
+		cc.stopHealthServer()
 		return err
 	}
 
 	err = cc.registerServiceMonitors()
 	if err != nil {
+		cc.stopHealthServer()
 		return err
 	}
 
 	proc.AddShutdownListener(func() {
-		cc.stopAllMonitors()
-		err := cc.deleteRegisterService()
+		grace := time.Duration(cc.consulConf.DrainGrace) * time.Second
+		err := cc.Drain(context.Background(), grace)
 		if err != nil {
 			logx.Errorf("deregister service %s error: %s", cc.serviceId, err.Error())
 		} else {
@@ -139,10 +161,21 @@ func (cc *CommonClient) RegisterService() error {
 }
 
 // DeregisterService deregisters the service from Consul.
+// The service is set critical before, so that the traffic drains, and the
+// built-in health server reports 503 from then on and is stopped after.
 // It returns an error if the deregistration fails.
 func (cc *CommonClient) DeregisterService() error {
 	cc.stopAllMonitors()
-	return cc.deleteRegisterService()
+	if cc.health != nil {
+		cc.health.markShuttingDown()
+	}
+	if err := cc.setRegisterServiceHealthStatus(api.HealthCritical); err != nil {
+		logx.Errorf("set service %s critical error: %v", cc.serviceId, err)
+	}
+
+	err := cc.deleteRegisterService()
+	cc.stopHealthServer()
+	return err
 }
 
 // GetServiceID returns the service ID.
@@ -205,63 +238,60 @@ func (cc *CommonClient) registerService() error {
 }
 
 // clientRegistration creates the service registration.
-// switch check type to create different health check, such as TTL, gRPC, HTTP.
+// It creates a health check of CheckType, or one per Checks, such as TTL, gRPC, HTTP, TCP.
 func (cc *CommonClient) clientRegistration() error {
 	reg := &api.AgentServiceRegistration{
-		ID:      cc.serviceId,       // Service node name
-		Name:    cc.consulConf.Key,  // Service name
-		Tags:    cc.consulConf.Tag,  // Tags, can be empty
-		Meta:    cc.consulConf.Meta, // Meta, can be empty
-		Port:    cc.servicePort,     // Service port
-		Address: cc.serviceHost,     // Service IP
-	}
-
-	switch cc.consulConf.CheckType {
-	case CheckTypeTTL:
-		reg.Checks = []*api.AgentServiceCheck{
-			{
-				CheckID:                        cc.serviceId,                          // Service node name
-				TTL:                            fmt.Sprintf("%ds", cc.consulConf.TTL), // Health check interval
-				Status:                         api.HealthPassing,
-				DeregisterCriticalServiceAfter: fmt.Sprintf("%ds", cc.consulConf.TTL*cc.consulConf.ExpiredTTL), // Deregistration time
-			},
-		}
+		ID:      cc.serviceId,          // Service node name
+		Name:    cc.consulConf.Key,     // Service name
+		Tags:    cc.consulConf.Tag,     // Tags, can be empty
+		Meta:    cc.registrationMeta(), // Meta, can be empty
+		Port:    cc.servicePort,        // Service port
+		Address: cc.serviceHost,        // Service IP
+	}
 
-	case CheckTypeGrpc:
-		reg.Checks = []*api.AgentServiceCheck{
-			{
-				CheckID:                        cc.serviceId,                                         // Service node name
-				GRPC:                           fmt.Sprintf("%s:%d", cc.serviceHost, cc.servicePort), // health check method
-				TLSServerName:                  cc.consulConf.CheckGrpc.TLSServerName,
-				TLSSkipVerify:                  cc.consulConf.CheckGrpc.TLSSkipVerify,
-				GRPCUseTLS:                     cc.consulConf.CheckGrpc.GRPCUseTLS,
-				Interval:                       fmt.Sprintf("%ds", cc.consulConf.TTL),          // health check interval
-				Timeout:                        fmt.Sprintf("%ds", cc.consulConf.CheckTimeout), // health check timeout
-				DeregisterCriticalServiceAfter: fmt.Sprintf("%ds", cc.consulConf.TTL*cc.consulConf.ExpiredTTL),
-				Status:                         api.HealthPassing,
-			},
-		}
-	case CheckTypeHttp:
-		// todo 可以考虑混合健康检查，例如TTL和HTTP
-		httpCheckHost := figureOutListenOn(fmt.Sprintf("%s:%d", cc.consulConf.CheckHttp.Host, cc.consulConf.CheckHttp.Port))
-		reg.Checks = []*api.AgentServiceCheck{
-			{
-				CheckID:                        cc.serviceId,                                                                                          // Service node name
-				HTTP:                           fmt.Sprintf("%s://%s%s", cc.consulConf.CheckHttp.Scheme, httpCheckHost, cc.consulConf.CheckHttp.Path), // health check url
-				Method:                         cc.consulConf.CheckHttp.Method,                                                                        // health check method
-				Interval:                       fmt.Sprintf("%ds", cc.consulConf.TTL),                                                                 // health check interval
-				Timeout:                        fmt.Sprintf("%ds", cc.consulConf.CheckTimeout),                                                        // health check timeout
-				DeregisterCriticalServiceAfter: fmt.Sprintf("%ds", cc.consulConf.TTL*cc.consulConf.ExpiredTTL),
-				Status:                         api.HealthPassing,
-			},
-		}
-	default:
-		return fmt.Errorf("unknown check type: %s", cc.consulConf.CheckType)
+	checks, err := cc.healthChecks()
+	if err != nil {
+		return err
+	}
+	for _, check := range checks {
+		reg.Checks = append(reg.Checks, cc.agentServiceCheck(check, api.HealthPassing))
 	}
 	cc.registration = reg
 	return nil
 }
 
+// registrationMeta returns the configured meta with the weight and zone of the instance.
+// The configured meta is copied, so that Conf.Meta isn't modified.
+func (cc *CommonClient) registrationMeta() map[string]string {
+	if cc.consulConf.Weight == 0 && len(cc.consulConf.Zone) == 0 {
+		return cc.consulConf.Meta
+	}
+
+	meta := make(map[string]string, len(cc.consulConf.Meta)+2)
+	for k, v := range cc.consulConf.Meta {
+		meta[k] = v
+	}
+	if cc.consulConf.Weight > 0 {
+		meta[MetaWeight] = strconv.Itoa(cc.consulConf.Weight)
+	}
+	if len(cc.consulConf.Zone) > 0 {
+		meta[MetaZone] = cc.consulConf.Zone
+	}
+
+	return meta
+}
+
+// stopHealthServer stops the built-in health server if enabled.
+func (cc *CommonClient) stopHealthServer() {
+	if cc.health == nil {
+		return
+	}
+
+	if err := cc.health.stop(); err != nil {
+		logx.Errorf("stop health server of service %s error: %v", cc.serviceId, err)
+	}
+}
+
 // deleteRegisterService deregisters the service from Consul.
 // It returns an error if the deregistration fails.
 func (cc *CommonClient) deleteRegisterService() error {
@@ -271,61 +301,33 @@ func (cc *CommonClient) deleteRegisterService() error {
 
 // setRegisterServiceHealthStatus sets the health status of the service.
 // status is the health status to set, such as api.HealthPassing or api.HealthCritical.
-// switch check type to set different health check status. such as TTL, gRPC, HTTP.
+// Every health check of the registration is set independently, such as TTL, gRPC, HTTP, TCP.
 func (cc *CommonClient) setRegisterServiceHealthStatus(status string) error {
-	switch cc.consulConf.CheckType {
-	case CheckTypeTTL:
-		check := api.AgentServiceCheck{
-			TTL:                            fmt.Sprintf("%ds", cc.consulConf.TTL),
-			Status:                         status,
-			DeregisterCriticalServiceAfter: fmt.Sprintf("%ds", cc.consulConf.TTL*cc.consulConf.ExpiredTTL),
-		}
-		return cc.apiClient.Agent().CheckRegister(&api.AgentCheckRegistration{
-			ID:                cc.registration.ID,
-			Name:              cc.registration.Name,
-			ServiceID:         cc.registration.ID,
-			AgentServiceCheck: check,
-		})
-	case CheckTypeHttp:
-		httpCheckHost := figureOutListenOn(fmt.Sprintf("%s:%d", cc.consulConf.CheckHttp.Host, cc.consulConf.CheckHttp.Port))
-		check := api.AgentServiceCheck{
-			HTTP:                           fmt.Sprintf("%s://%s%s", cc.consulConf.CheckHttp.Scheme, httpCheckHost, cc.consulConf.CheckHttp.Path), // health check url
-			Method:                         cc.consulConf.CheckHttp.Method,                                                                        // health check method
-			Interval:                       fmt.Sprintf("%ds", cc.consulConf.TTL),                                                                 // health check interval
-			Timeout:                        fmt.Sprintf("%ds", cc.consulConf.CheckTimeout),                                                        // health check timeout
-			DeregisterCriticalServiceAfter: fmt.Sprintf("%ds", cc.consulConf.TTL*cc.consulConf.ExpiredTTL),                                        // Deregistration time
-			Status:                         status,
-		}
-		return cc.apiClient.Agent().CheckRegister(&api.AgentCheckRegistration{
-			ID:                cc.registration.ID,
-			Name:              cc.registration.Name,
+	checks, err := cc.healthChecks()
+	if err != nil {
+		return err
+	}
+
+	for _, check := range checks {
+		asc := cc.agentServiceCheck(check, status)
+		asc.CheckID, asc.Name = "", ""
+		err = cc.apiClient.Agent().CheckRegister(&api.AgentCheckRegistration{
+			ID:                check.ID,
+			Name:              check.ConsulName,
 			ServiceID:         cc.registration.ID,
-			AgentServiceCheck: check,
+			AgentServiceCheck: *asc,
 		})
-	case CheckTypeGrpc:
-		check := api.AgentServiceCheck{
-			GRPC:                           fmt.Sprintf("%s:%d", cc.serviceHost, cc.servicePort), // health check method
-			TLSServerName:                  cc.consulConf.CheckGrpc.TLSServerName,
-			TLSSkipVerify:                  cc.consulConf.CheckGrpc.TLSSkipVerify,
-			GRPCUseTLS:                     cc.consulConf.CheckGrpc.GRPCUseTLS,                             // health check method
-			Interval:                       fmt.Sprintf("%ds", cc.consulConf.TTL),                          // health check interval
-			Timeout:                        fmt.Sprintf("%ds", cc.consulConf.CheckTimeout),                 // health check timeout
-			DeregisterCriticalServiceAfter: fmt.Sprintf("%ds", cc.consulConf.TTL*cc.consulConf.ExpiredTTL), // Deregistration time
-			Status:                         status,
+		if err != nil {
+			return fmt.Errorf("set check %s status %s error: %v", check.ID, status, err)
 		}
-		return cc.apiClient.Agent().CheckRegister(&api.AgentCheckRegistration{
-			ID:                cc.registration.ID,
-			Name:              cc.registration.Name,
-			ServiceID:         cc.registration.ID,
-			AgentServiceCheck: check,
-		})
-	default:
-		return fmt.Errorf("unknown check type: %s", cc.consulConf.CheckType)
 	}
+
+	return nil
 }
 
-// getRegisterServiceHealthStatus returns the health status of the service.
-func (cc *CommonClient) getRegisterServiceHealthStatus() (string, error) {
+// getRegisterServiceHealthStatus returns the health status of the service,
+// aggregated over the checks of checkIDs if any, otherwise over all checks.
+func (cc *CommonClient) getRegisterServiceHealthStatus(checkIDs ...string) (string, error) {
 	service, _, err := cc.apiClient.Agent().Service(cc.serviceId, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to get service %s: %v", cc.serviceId, err)
@@ -338,7 +340,11 @@ func (cc *CommonClient) getRegisterServiceHealthStatus() (string, error) {
 
 	for _, entry := range serviceEntries {
 		if entry.Service.ID == cc.serviceId {
-			return entry.Checks.AggregatedStatus(), nil
+			checks := filterChecks(entry.Checks, checkIDs)
+			if len(checkIDs) > 0 && len(checks) == 0 {
+				return "", fmt.Errorf("checks %v of service %s not found", checkIDs, cc.serviceId)
+			}
+			return checks.AggregatedStatus(), nil
 		}
 	}
 	return "", fmt.Errorf("service %s not found in health check results", cc.serviceId)
@@ -346,9 +352,10 @@ func (cc *CommonClient) getRegisterServiceHealthStatus() (string, error) {
 
 // registerServiceHealthStatus checks if the service health status is as expected.
 // status is the expected health status, such as api.HealthPassing or api.HealthCritical.
+// checkIDs limits the checks, all checks if empty.
 // It returns true if the status matches, otherwise false.
-func (cc *CommonClient) registerServiceHealthStatus(status string) (bool, error) {
-	ss, err := cc.getRegisterServiceHealthStatus()
+func (cc *CommonClient) registerServiceHealthStatus(status string, checkIDs ...string) (bool, error) {
+	ss, err := cc.getRegisterServiceHealthStatus(checkIDs...)
 	if err != nil {
 		return false, err
 	}
@@ -360,11 +367,10 @@ func (cc *CommonClient) registerServiceHealthStatus(status string) (bool, error)
 	return true, nil
 }
 
-// registerServiceMonitors registers the service monitors based on the check type.
-// switch check type to register different monitors. such as TTL, gRPC, HTTP.
-// default monitor is TTLCheckMonitorFunc where type is TTL
-// default monitor is HttpCheckMonitorFunc where type is HTTP
-// default monitor is GrpcCheckMonitorFunc where type is gRPC
+// registerServiceMonitors registers the service monitors based on the check types.
+// default monitor is TTLCheckMonitorFunc if any check is TTL
+// default monitor is HttpCheckMonitorFunc if any check is HTTP, gRPC or TCP
+// each check is monitored by its own goroutine at its own interval
 // you can use MustNewService opts param to add custom monitors.
 func (cc *CommonClient) registerServiceMonitors() error {
 	cc.monitorMutex.RLock()
@@ -374,17 +380,26 @@ func (cc *CommonClient) registerServiceMonitors() error {
 	if hasNoFuncs {
 		cc.monitorMutex.Lock()
 		if len(cc.monitorFuncs) == 0 {
-			switch cc.consulConf.CheckType {
-			case CheckTypeTTL:
+			checks, err := cc.healthChecks()
+			if err != nil {
+				cc.monitorMutex.Unlock()
+				return err
+			}
+
+			var ttl, other bool
+			for _, check := range checks {
+				if check.Type == CheckTypeTTL {
+					ttl = true
+				} else {
+					other = true
+				}
+			}
+			if ttl {
 				cc.monitorFuncs = append(cc.monitorFuncs, TTLCheckMonitorFunc())
-			case CheckTypeGrpc:
-				cc.monitorFuncs = append(cc.monitorFuncs, HttpCheckMonitorFunc())
-			case CheckTypeHttp:
+			}
+			if other {
 				cc.monitorFuncs = append(cc.monitorFuncs, HttpCheckMonitorFunc())
-			default:
-				return fmt.Errorf("unknown check type: %s", cc.consulConf.CheckType)
 			}
-
 		}
 		cc.monitorMutex.Unlock()
 	}
@@ -430,58 +445,104 @@ func (s *MonitorState) Close() {
 }
 
 // TTLCheckMonitorFunc is the monitor function for TTL check.
+// Every TTL check of the registration is updated independently at its own interval.
 func TTLCheckMonitorFunc() MonitorFunc {
+	return checksMonitorFunc(func(typ string) bool {
+		return typ == CheckTypeTTL
+	}, ttlCheckMonitor)
+}
+
+// checksMonitorFunc returns a MonitorFunc running monitor for every check of the
+// types matched by match, until stopCh is closed.
+func checksMonitorFunc(match func(typ string) bool,
+	monitor func(cc *CommonClient, check healthCheck, stopCh <-chan struct{})) MonitorFunc {
 	return func(cc *CommonClient, stopCh <-chan struct{}) {
-		var ttlTicker time.Duration
-		if cc.consulConf.TTL > 0 {
-			ttlTicker = time.Duration(cc.consulConf.TTL-1) * time.Second
-			if ttlTicker < time.Second {
-				ttlTicker = time.Second
-			}
-		} else {
-			ttlTicker = 1 * time.Second
+		checks, err := cc.healthChecks()
+		if err != nil {
+			logx.Errorf("Monitor function error for service %s: %v", cc.serviceId, err)
+			<-stopCh
+			return
 		}
 
-		state := &MonitorState{
-			RetryCount:     0,
-			BackoffTime:    1 * time.Second,
-			MaxRetries:     5,
-			MaxBackoffTime: 30 * time.Second,
-			OriginalTTL:    ttlTicker,
-			Ticker:         time.NewTicker(ttlTicker),
-		}
-		defer state.Close()
-
-		for {
-			select {
-			case <-state.Ticker.C:
-				err := TTLMonitorLogic(cc, state)
-				if err != nil {
-					logx.Errorf("Monitor function error for service %s: %v", cc.serviceId, err)
-				}
-			case <-stopCh:
-				logx.Infof("Service monitor for %s stopped gracefully", cc.serviceId)
-				return
+		var wg sync.WaitGroup
+		for _, check := range checks {
+			if !match(check.Type) {
+				continue
 			}
+
+			wg.Add(1)
+			go func(check healthCheck) {
+				defer wg.Done()
+				monitor(cc, check, stopCh)
+			}(check)
 		}
+		wg.Wait()
+	}
+}
 
+// ttlCheckMonitor updates the TTL check every Interval - 1 seconds until stopCh is closed.
+func ttlCheckMonitor(cc *CommonClient, check healthCheck, stopCh <-chan struct{}) {
+	runCheckMonitor(cc, check, check.Interval-1, TTLMonitorLogic, stopCh)
+}
+
+// runCheckMonitor runs logic for the check every interval seconds, at least every
+// second, until stopCh is closed.
+func runCheckMonitor(cc *CommonClient, check healthCheck, interval int,
+	logic func(cc *CommonClient, state *MonitorState) error, stopCh <-chan struct{}) {
+	ticker := time.Duration(interval) * time.Second
+	if ticker < time.Second {
+		ticker = time.Second
+	}
+
+	state := &MonitorState{
+		RetryCount:     0,
+		BackoffTime:    1 * time.Second,
+		MaxRetries:     5,
+		MaxBackoffTime: 30 * time.Second,
+		OriginalTTL:    ticker,
+		CheckID:        check.ID,
+		Ticker:         time.NewTicker(ticker),
+	}
+	defer state.Close()
+
+	for {
+		select {
+		case <-state.Ticker.C:
+			err := logic(cc, state)
+			if err != nil {
+				logx.Errorf("Monitor function error for service %s: %v", cc.serviceId, err)
+			}
+		case <-stopCh:
+			logx.Infof("Service monitor for %s stopped gracefully", check.ID)
+			return
+		}
 	}
 }
 
 // TTLMonitorLogic is the logic for TTL monitor.
 func TTLMonitorLogic(cc *CommonClient, state *MonitorState) error {
 
+	checkID := state.CheckID
+	if len(checkID) == 0 {
+		checkID = cc.serviceId
+	}
+
 	// update TTL
-	err := cc.apiClient.Agent().UpdateTTL(cc.serviceId, "", "passing")
+	err := cc.apiClient.Agent().UpdateTTL(checkID, "", "passing")
 	if err == nil {
-		logx.Infof("Service %s TTL updated successfully", cc.serviceId)
+		logx.Infof("Service %s TTL updated successfully", checkID)
 		state.RetryCount = 0
 		state.BackoffTime = 1 * time.Second
 		state.Ticker.Reset(state.OriginalTTL)
 		return nil
 	}
 
-	registered, err := cc.registerServiceHealthStatus(api.HealthPassing)
+	var registered bool
+	if len(cc.consulConf.Checks) > 0 {
+		registered, err = cc.registerServiceHealthStatus(api.HealthPassing, checkID)
+	} else {
+		registered, err = cc.registerServiceHealthStatus(api.HealthPassing)
+	}
 	if err != nil {
 		logx.Error(err)
 	} else {
@@ -524,57 +585,53 @@ func TTLMonitorLogic(cc *CommonClient, state *MonitorState) error {
 }
 
 // HttpCheckMonitorFunc is the monitor function for HTTP check.
+// Every HTTP, gRPC and TCP check of the registration is watched independently at its own interval.
 func HttpCheckMonitorFunc() MonitorFunc {
+	return checksMonitorFunc(func(typ string) bool {
+		return typ != CheckTypeTTL
+	}, httpCheckMonitor)
+}
 
-	return func(cc *CommonClient, stopCh <-chan struct{}) {
-		var ttlTicker time.Duration
-		if cc.consulConf.TTL > 0 {
-			ttlTicker = time.Duration(cc.consulConf.TTL-1) * time.Second
-			if ttlTicker < time.Second {
-				ttlTicker = time.Second
-			}
-		} else {
-			ttlTicker = 1 * time.Second
-		}
-
-		state := &MonitorState{
-			RetryCount:     0,
-			BackoffTime:    1 * time.Second,
-			MaxRetries:     5,
-			MaxBackoffTime: 30 * time.Second,
-			OriginalTTL:    ttlTicker,
-			Ticker:         time.NewTicker(ttlTicker),
-		}
-		defer state.Close()
-
-		for {
-			select {
-			case <-state.Ticker.C:
-				err := HttpMonitorLogic(cc, state)
-				if err != nil {
-					logx.Errorf("Monitor function error for service %s: %v", cc.serviceId, err)
-				}
-			case <-stopCh:
-				logx.Infof("Service monitor for %s stopped gracefully", cc.serviceId)
-				return
-			}
-		}
-	}
+// httpCheckMonitor watches the check every Interval seconds until stopCh is closed.
+func httpCheckMonitor(cc *CommonClient, check healthCheck, stopCh <-chan struct{}) {
+	runCheckMonitor(cc, check, check.Interval, HttpMonitorLogic, stopCh)
 }
 
 // HttpMonitorLogic is the logic for HTTP monitor.
+// The check is run by Consul, so its status is only logged and never overwritten.
+// The service is re-registered only if the check is gone, e.g. after an agent restart.
 func HttpMonitorLogic(cc *CommonClient, state *MonitorState) error {
-	registered, err := cc.registerServiceHealthStatus(api.HealthPassing)
-	if err != nil {
+	checkID := state.CheckID
+	if len(checkID) == 0 {
+		checkID = cc.serviceId
+	}
+
+	var checkIDs []string
+	if len(cc.consulConf.Checks) > 0 {
+		checkIDs = []string{checkID}
+	}
+
+	status, err := cc.getRegisterServiceHealthStatus(checkIDs...)
+	registered := err == nil
+	switch {
+	case err != nil:
 		logx.Error(err)
-	} else {
-		logx.Infof("Service %s health check passed", cc.serviceId)
-		registered = true
+	case status == api.HealthPassing:
+		logx.Infof("Service %s health check passed", checkID)
+	default:
+		logx.Errorf("Service %s health check is %s", checkID, status)
 	}
 
 	state.Mutex.Lock()
 	defer state.Mutex.Unlock()
-	if !registered && state.RetryCount < state.MaxRetries {
+	if registered {
+		state.RetryCount = 0
+		state.BackoffTime = 1 * time.Second
+		state.Ticker.Reset(state.OriginalTTL)
+		return nil
+	}
+
+	if state.RetryCount < state.MaxRetries {
 		logx.Infof("Attempting to re-register service %s (retry %d/%d)...", cc.serviceId, state.RetryCount+1, state.MaxRetries)
 		err = cc.registerServiceWithPassingHealth()
 		if err != nil {
@@ -598,15 +655,32 @@ func HttpMonitorLogic(cc *CommonClient, state *MonitorState) error {
 	}
 
 	// 达到最大重试次数
-	if !registered {
-		logx.Errorf("Max retries reached for service %s. Resetting retry counter and backoff time.", cc.serviceId)
-		state.RetryCount = 0
-		state.BackoffTime = 1 * time.Second
-		state.Ticker.Reset(state.BackoffTime)
-	}
+	logx.Errorf("Max retries reached for service %s. Resetting retry counter and backoff time.", cc.serviceId)
+	state.RetryCount = 0
+	state.BackoffTime = 1 * time.Second
+	state.Ticker.Reset(state.BackoffTime)
 	return nil
 }
 
+// filterChecks returns the checks of checkIDs, all checks if checkIDs is empty.
+func filterChecks(checks api.HealthChecks, checkIDs []string) api.HealthChecks {
+	if len(checkIDs) == 0 {
+		return checks
+	}
+
+	filtered := make(api.HealthChecks, 0, len(checkIDs))
+	for _, check := range checks {
+		for _, id := range checkIDs {
+			if check.CheckID == id {
+				filtered = append(filtered, check)
+				break
+			}
+		}
+	}
+
+	return filtered
+}
+
 // figureOutListenOn figures out the listen on address.
 // if your host is "0.0.0.0", it will be replaced with the environment variable POD_IP or the internal IP.
 // example: "0.0.0.0:8080" -> "10.10.10.10:8080"
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,9 +24,42 @@ type resolvr struct {
 }
 
 type consulAddr struct {
-	Addr string
-	Port int
-	Tags []string
+	Addr   string
+	Port   int
+	Tags   []string
+	Meta   map[string]string
+	Weight int
+	Zone   string
+	Local  bool
+}
+
+// ServiceMeta is the service meta of an endpoint, kept in resolver.Address.Attributes.
+type ServiceMeta map[string]string
+
+// Equal reports whether m and o hold the same meta, used by attributes.Attributes.Equal.
+func (m ServiceMeta) Equal(o any) bool {
+	om, ok := o.(ServiceMeta)
+	if !ok || len(m) != len(om) {
+		return false
+	}
+	for k, v := range m {
+		if ov, ok := om[k]; !ok || ov != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AddressMeta returns the service meta of an address resolved by the consul resolver,
+// e.g. in a custom balancer. It returns nil if the service has no meta.
+func AddressMeta(addr resolver.Address) map[string]string {
+	if addr.Attributes == nil {
+		return nil
+	}
+
+	meta, _ := addr.Attributes.Value(consulMeta).(ServiceMeta)
+	return meta
 }
 
 func (r *resolvr) ResolveNow(resolver.ResolveNowOptions) {}
@@ -50,7 +84,10 @@ func watchConsulService(ctx context.Context, s servicer, tgt target, out chan<-
 	}
 	go func() {
 		var lastIndex uint64
+		// whether a non-empty set has been served, kept on empty sets unless allowed
+		var served bool
 		for {
+			start := time.Now()
 			ss, meta, err := s.Service(
 				tgt.Service,
 				tgt.Tag,
@@ -64,7 +101,10 @@ func watchConsulService(ctx context.Context, s servicer, tgt target, out chan<-
 					RequireConsistent: tgt.RequireConsistent,
 				},
 			)
+			metricResolverWatchDuration.Observe(time.Since(start).Milliseconds(), tgt.Service)
 			if err != nil {
+				// the last served endpoints are kept
+				metricResolverWatchErrors.Inc(tgt.Service)
 				logx.Errorf("[Consul resolver] Couldn't fetch endpoints. target={%s}; error={%v}", tgt.String(), err)
 
 				time.Sleep(bck.Duration())
@@ -85,16 +125,33 @@ func watchConsulService(ctx context.Context, s servicer, tgt target, out chan<-
 				if s.Service.Address == "" {
 					address = s.Node.Address
 				}
+				zone := s.Service.Meta[MetaZone]
+				if len(zone) == 0 && s.Node != nil {
+					zone = s.Node.Datacenter
+				}
+				weight, _ := strconv.Atoi(s.Service.Meta[MetaWeight])
 				ee = append(ee, &consulAddr{
-					Addr: address,
-					Port: s.Service.Port,
-					Tags: s.Service.Tags,
+					Addr:   address,
+					Port:   s.Service.Port,
+					Tags:   s.Service.Tags,
+					Meta:   s.Service.Meta,
+					Weight: weight,
+					Zone:   zone,
+					Local:  len(tgt.Zone) > 0 && zone == tgt.Zone,
 				})
 			}
 
 			if tgt.Limit != 0 && len(ee) > tgt.Limit {
 				ee = ee[:tgt.Limit]
 			}
+			if len(ee) == 0 && served && !tgt.AllowEmpty {
+				logx.Errorf("[Consul resolver] No endpoints for target={%s}, keeping the last endpoints", tgt.String())
+				continue
+			}
+			served = len(ee) > 0
+			metricResolverEndpoints.Set(float64(len(ee)), tgt.Service)
+			metricResolverLastUpdate.Set(float64(time.Now().Unix()), tgt.Service)
+
 			select {
 			case res <- ee:
 				continue
@@ -115,25 +172,43 @@ func watchConsulService(ctx context.Context, s servicer, tgt target, out chan<-
 	}
 }
 
-func populateEndpoints(ctx context.Context, clientConn resolver.ClientConn, input <-chan []*consulAddr) {
+// populateEndpoints pushes the endpoints of input and the service config of configs
+// to clientConn. A service config received before the first endpoints is pushed
+// along with them, and an invalid one is ignored.
+func populateEndpoints(ctx context.Context, clientConn resolver.ClientConn, input <-chan []*consulAddr,
+	configs <-chan string) {
+	var state resolver.State
+	var resolved bool
 	for {
 		select {
+		case raw := <-configs:
+			sc, err := parseServiceConfig(clientConn, raw)
+			if err != nil {
+				// keep the last valid service config, gRPC fails the channel on an invalid one without it
+				logx.Errorf("[Consul resolver] Invalid service config, ignored: %v", err)
+				continue
+			}
+			state.ServiceConfig = sc
+			if resolved {
+				_ = clientConn.UpdateState(state)
+			}
 		case cc := <-input:
-			connsSet := make(map[string][]string, len(cc))
+			connsSet := make(map[string]*consulAddr, len(cc))
 			for _, c := range cc {
-				connsSet[fmt.Sprintf("%s:%d", c.Addr, c.Port)] = c.Tags
+				connsSet[fmt.Sprintf("%s:%d", c.Addr, c.Port)] = c
 			}
 			conns := make([]resolver.Address, 0, len(connsSet))
-			for c, tags := range connsSet {
-				rAddr := resolver.Address{Addr: c}
-				if tags != nil {
-					rAddr.Attributes = attributes.New(consulTags, strings.Join(tags, ","))
-				}
-				conns = append(conns, rAddr)
+			for c, addr := range connsSet {
+				conns = append(conns, resolver.Address{
+					Addr:       c,
+					Attributes: addressAttributes(addr),
+				})
 			}
 
 			sort.Sort(byAddressString(conns)) // Don't replace the same address list in the balancer
-			_ = clientConn.UpdateState(resolver.State{Addresses: conns})
+			state.Addresses = conns
+			resolved = true
+			_ = clientConn.UpdateState(state)
 		case <-ctx.Done():
 			logx.Info("[Consul resolver] Watch has been finished")
 			return
@@ -141,6 +216,37 @@ func populateEndpoints(ctx context.Context, clientConn resolver.ClientConn, inpu
 	}
 }
 
+// addressAttributes returns the attributes of addr, the tags, meta, weight, zone and
+// whether it is in the zone of the target, nil if none of them is set.
+func addressAttributes(addr *consulAddr) *attributes.Attributes {
+	var attrs *attributes.Attributes
+	set := func(key string, val any) {
+		if attrs == nil {
+			attrs = attributes.New(key, val)
+		} else {
+			attrs = attrs.WithValue(key, val)
+		}
+	}
+
+	if addr.Tags != nil {
+		set(consulTags, strings.Join(addr.Tags, ","))
+	}
+	if len(addr.Meta) > 0 {
+		set(consulMeta, ServiceMeta(addr.Meta))
+	}
+	if addr.Weight > 0 {
+		set(consulWeight, addr.Weight)
+	}
+	if len(addr.Zone) > 0 {
+		set(consulZone, addr.Zone)
+	}
+	if addr.Local {
+		set(consulLocal, true)
+	}
+
+	return attrs
+}
+
 // byAddressString sorts resolver.Address by Address Field  sorting in increasing order.
 type byAddressString []resolver.Address
 
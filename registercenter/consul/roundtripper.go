@@ -0,0 +1,214 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const defaultRetries = 2
+
+type (
+	// RoundTripper is an http.RoundTripper resolving the host of request URLs as
+	// Consul service names, e.g. http://user-api/users/1 is sent to a healthy
+	// instance of user-api picked by P2C, and retried on other instances on errors.
+	RoundTripper struct {
+		base        http.RoundTripper
+		consulURL   url.URL
+		retries     int
+		retryAll    bool
+		lock        sync.Mutex
+		discoveries map[string]*Discovery
+	}
+
+	// RoundTripperOption is the function signature for RoundTripper options.
+	RoundTripperOption func(*RoundTripper)
+)
+
+// MustNewRoundTripper returns a RoundTripper, exits on errors.
+func MustNewRoundTripper(consulURL string, opts ...RoundTripperOption) *RoundTripper {
+	rt, err := NewRoundTripper(consulURL, opts...)
+	logx.Must(err)
+	return rt
+}
+
+// NewRoundTripper returns a RoundTripper. consulURL is a consul URL without the service,
+// its parameters apply to every service, e.g. consul://127.0.0.1:8500?healthy=true
+func NewRoundTripper(consulURL string, opts ...RoundTripperOption) (*RoundTripper, error) {
+	u, err := url.Parse(consulURL)
+	if err != nil {
+		return nil, fmt.Errorf("wrong consul URL: %w", err)
+	}
+	// validate the URL and its parameters with a placeholder service
+	probe := *u
+	probe.Path = "/service"
+	if _, err = parseURL(probe); err != nil {
+		return nil, fmt.Errorf("wrong consul URL: %w", err)
+	}
+
+	rt := &RoundTripper{
+		base:        http.DefaultTransport,
+		consulURL:   *u,
+		retries:     defaultRetries,
+		discoveries: make(map[string]*Discovery),
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+
+	return rt, nil
+}
+
+// RoundTrip sends req to an instance of the service named by its host. A request
+// failed with a transport error is retried on another instance if its body can be
+// replayed and it is idempotent or was never sent, responses are never retried.
+func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(req.URL.Hostname()) == 0 {
+		return nil, fmt.Errorf("empty service name in URL: %s", req.URL.String())
+	}
+
+	d, err := rt.discovery(req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if err = d.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	tried := make(map[string]bool)
+	for attempt := 0; ; attempt++ {
+		e, err := d.pick(tried)
+		if err != nil {
+			return nil, err
+		}
+		tried[e.addr] = true
+
+		outReq, err := rewriteRequest(req, e.addr, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		done := e.start()
+		resp, err := rt.base.RoundTrip(outReq)
+		done()
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= rt.retries || !rt.retryable(req, err) || req.Context().Err() != nil {
+			return nil, err
+		}
+
+		logx.WithContext(req.Context()).Errorf("[Consul discovery] Request to %s of %s failed, retrying: %v",
+			e.addr, req.URL.Hostname(), err)
+	}
+}
+
+// Close stops watching all services.
+func (rt *RoundTripper) Close() {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+
+	for _, d := range rt.discoveries {
+		d.Close()
+	}
+	rt.discoveries = make(map[string]*Discovery)
+}
+
+// discovery returns the Discovery of service, started on the first request.
+func (rt *RoundTripper) discovery(service string) (*Discovery, error) {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+
+	if d, ok := rt.discoveries[service]; ok {
+		return d, nil
+	}
+
+	u := rt.consulURL
+	u.Path = "/" + service
+	d, err := NewDiscovery(u.String())
+	if err != nil {
+		return nil, err
+	}
+	rt.discoveries[service] = d
+
+	return d, nil
+}
+
+// rewriteRequest returns a copy of req sent to addr, with a fresh body for retries.
+func rewriteRequest(req *http.Request, addr string, attempt int) (*http.Request, error) {
+	outReq := req.Clone(req.Context())
+	outReq.URL.Host = addr
+	outReq.Host = addr
+	if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		outReq.Body = body
+	}
+
+	return outReq, nil
+}
+
+// retryable reports whether req failed with err can be sent again. Non-idempotent
+// requests, e.g. POST, are only retried if the connection couldn't be dialed, since
+// the instance may have processed them, unless WithRetryNonIdempotent is set.
+func (rt *RoundTripper) retryable(req *http.Request, err error) bool {
+	if !replayable(req) {
+		return false
+	}
+
+	return rt.retryAll || idempotent(req) || isDialError(err)
+}
+
+// idempotent follows net/http, requests with an Idempotency-Key header are idempotent.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	_, ok := req.Header["X-Idempotency-Key"]
+	return ok
+}
+
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// WithTransport sets the underlying transport, http.DefaultTransport by default.
+func WithTransport(base http.RoundTripper) RoundTripperOption {
+	return func(rt *RoundTripper) {
+		rt.base = base
+	}
+}
+
+// WithRetries sets the retries on other instances after a transport error, 2 by default.
+func WithRetries(retries int) RoundTripperOption {
+	return func(rt *RoundTripper) {
+		rt.retries = retries
+	}
+}
+
+// WithRetryNonIdempotent retries non-idempotent requests, e.g. POST, after any transport
+// error, not only dial errors. Only use it if the services tolerate duplicated requests.
+func WithRetryNonIdempotent() RoundTripperOption {
+	return func(rt *RoundTripper) {
+		rt.retryAll = true
+	}
+}
+
+var _ http.RoundTripper = (*RoundTripper)(nil)
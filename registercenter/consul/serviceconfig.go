@@ -0,0 +1,93 @@
+package consul
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/jpillora/backoff"
+	"github.com/zeromicro/go-zero/core/logx"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+type kvGetter interface {
+	Get(string, *api.QueryOptions) (*api.KVPair, *api.QueryMeta, error)
+}
+
+// watchServiceConfig watches the KV key of the service-config target parameter
+// and sends its JSON value to out on every change, an empty string if the key is deleted.
+func watchServiceConfig(ctx context.Context, kv kvGetter, tgt target, out chan<- string) {
+	bck := &backoff.Backoff{
+		Factor: 2,
+		Jitter: true,
+		Min:    10 * time.Millisecond,
+		Max:    tgt.MaxBackoff,
+	}
+
+	var lastIndex uint64
+	var last string
+	first := true
+	for {
+		q := &api.QueryOptions{
+			WaitIndex:         lastIndex,
+			WaitTime:          tgt.Wait,
+			Datacenter:        tgt.Dc,
+			AllowStale:        tgt.AllowStale,
+			RequireConsistent: tgt.RequireConsistent,
+		}
+		pair, meta, err := kv.Get(tgt.ServiceConfig, q.WithContext(ctx))
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logx.Errorf("[Consul resolver] Couldn't fetch service config. key={%s}; target={%s}; error={%v}",
+				tgt.ServiceConfig, tgt.String(), err)
+
+			select {
+			case <-time.After(bck.Duration()):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		bck.Reset()
+		// the index goes backwards if the key is recreated or the cluster is restored
+		if meta.LastIndex < lastIndex {
+			lastIndex = 0
+		} else {
+			lastIndex = meta.LastIndex
+		}
+
+		var raw string
+		if pair != nil {
+			raw = string(pair.Value)
+		}
+		if !first && raw == last {
+			continue
+		}
+		first = false
+		last = raw
+
+		select {
+		case out <- raw:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// parseServiceConfig parses raw by clientConn, nil for an empty raw to use the default service config.
+func parseServiceConfig(clientConn resolver.ClientConn, raw string) (*serviceconfig.ParseResult, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	sc := clientConn.ParseServiceConfig(raw)
+	if sc.Err != nil {
+		return nil, sc.Err
+	}
+
+	return sc, nil
+}
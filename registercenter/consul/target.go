@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,6 +31,9 @@ type target struct {
 	Dc                string        `key:"dc,optional"`
 	AllowStale        bool          `key:"allow-stale,optional"`
 	RequireConsistent bool          `key:"require-consistent,optional"`
+	Zone              string        `key:"zone,optional"`
+	ServiceConfig     string        `key:"service-config,optional"`
+	AllowEmpty        bool          `key:"allow-empty,optional"`
 }
 
 func (t *target) String() string {
@@ -44,11 +49,11 @@ func parseURL(rawURL url.URL) (target, error) {
 	}
 
 	var tgt target
-	params := make(map[string]interface{}, len(rawURL.Query()))
-	for name, value := range rawURL.Query() {
-		params[name] = value[0]
+	params, err := typedParams(rawURL.Query())
+	if err != nil {
+		return target{}, errors.Wrap(err, "Malformed URL parameters")
 	}
-	err := mapping.UnmarshalKey(params, &tgt)
+	err = mapping.UnmarshalKey(params, &tgt)
 	if err != nil {
 		return target{}, errors.Wrap(err, "Malformed URL parameters")
 	}
@@ -68,6 +73,43 @@ func parseURL(rawURL url.URL) (target, error) {
 	return tgt, nil
 }
 
+// typedParams converts the query values of the bool and int fields of target,
+// mapping doesn't convert strings into them, e.g. healthy=true or limit=2.
+// Durations are kept as strings, which mapping parses itself.
+func typedParams(query url.Values) (map[string]interface{}, error) {
+	params := make(map[string]interface{}, len(query))
+	for name, value := range query {
+		params[name] = value[0]
+	}
+
+	tp := reflect.TypeOf(target{})
+	for i := 0; i < tp.NumField(); i++ {
+		field := tp.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("key"), ",")
+		val, ok := params[name].(string)
+		if len(name) == 0 || !ok || field.Type == reflect.TypeOf(time.Duration(0)) {
+			continue
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return nil, errors.Errorf("invalid bool value of %s: %s", name, val)
+			}
+			params[name] = b
+		case reflect.Int:
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return nil, errors.Errorf("invalid int value of %s: %s", name, val)
+			}
+			params[name] = n
+		}
+	}
+
+	return params, nil
+}
+
 // consulConfig returns config based on the parsed target.
 // It uses custom http-client.
 func (t *target) consulConfig() *api.Config {
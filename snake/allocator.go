@@ -0,0 +1,150 @@
+package snake
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	consulApi "github.com/hashicorp/consul/api"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zeromicro/go-zero/core/stringx"
+)
+
+// ErrWorkerIDLeaseLost is returned by Generator once the worker id lease can no longer be renewed.
+var ErrWorkerIDLeaseLost = errors.New("worker id lease lost, refusing to generate id")
+
+type (
+	// WorkerIDAllocator claims a worker id from a shared slot space and keeps it leased
+	// for as long as the process runs.
+	WorkerIDAllocator interface {
+		// Acquire claims a free worker id in [0, maxWorkerID] and starts renewing its lease.
+		Acquire(maxWorkerID int64) (int64, error)
+		// Lost returns a channel that is closed once the lease is lost.
+		Lost() <-chan struct{}
+		// Release stops renewing the lease and frees the claimed worker id.
+		Release() error
+	}
+
+	// leaseState tracks whether a lease has been lost.
+	leaseState struct {
+		lost     chan struct{}
+		lostOnce sync.Once
+	}
+
+	// MemoryAllocatorPool is an in-process worker id space shared by MemoryAllocators,
+	// useful in tests where several snakes must not collide.
+	MemoryAllocatorPool struct {
+		lock  sync.Mutex
+		slots map[int64]*MemoryAllocator
+	}
+
+	// MemoryAllocator is a WorkerIDAllocator backed by a MemoryAllocatorPool.
+	MemoryAllocator struct {
+		leaseState
+		pool     *MemoryAllocatorPool
+		workerID int64
+		acquired bool
+	}
+)
+
+func newLeaseState() leaseState {
+	return leaseState{lost: make(chan struct{})}
+}
+
+// Lost returns a channel that is closed once the lease is lost.
+func (l *leaseState) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *leaseState) markLost() {
+	l.lostOnce.Do(func() {
+		close(l.lost)
+	})
+}
+
+// NewMemoryAllocatorPool returns an empty MemoryAllocatorPool.
+func NewMemoryAllocatorPool() *MemoryAllocatorPool {
+	return &MemoryAllocatorPool{slots: make(map[int64]*MemoryAllocator)}
+}
+
+// NewAllocator returns a MemoryAllocator that claims worker ids from p.
+func (p *MemoryAllocatorPool) NewAllocator() *MemoryAllocator {
+	return &MemoryAllocator{
+		leaseState: newLeaseState(),
+		pool:       p,
+	}
+}
+
+// Acquire claims the lowest free worker id in [0, maxWorkerID].
+func (a *MemoryAllocator) Acquire(maxWorkerID int64) (int64, error) {
+	a.pool.lock.Lock()
+	defer a.pool.lock.Unlock()
+
+	if a.acquired {
+		return a.workerID, nil
+	}
+
+	for slot := int64(0); slot <= maxWorkerID; slot++ {
+		if _, ok := a.pool.slots[slot]; ok {
+			continue
+		}
+		a.pool.slots[slot] = a
+		a.workerID = slot
+		a.acquired = true
+		return slot, nil
+	}
+
+	return 0, fmt.Errorf("no free worker id in [0, %d]", maxWorkerID)
+}
+
+// Release frees the claimed worker id.
+func (a *MemoryAllocator) Release() error {
+	a.pool.lock.Lock()
+	defer a.pool.lock.Unlock()
+
+	if a.acquired && a.pool.slots[a.workerID] == a {
+		delete(a.pool.slots, a.workerID)
+	}
+	a.acquired = false
+	return nil
+}
+
+// Expire simulates a lost lease: the worker id is freed and Lost is closed.
+func (a *MemoryAllocator) Expire() {
+	_ = a.Release()
+	a.markLost()
+}
+
+// newAllocator builds the WorkerIDAllocator described by c, nil if none is configured.
+func newAllocator(c AllocatorConf) (WorkerIDAllocator, error) {
+	ttl := time.Duration(c.TTL) * time.Second
+
+	switch c.Type {
+	case AllocatorTypeRedis:
+		store, err := redis.NewRedis(c.Redis)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisAllocator(store, c.Key, ttl), nil
+	case AllocatorTypeConsul:
+		client, err := consulApi.NewClient(&consulApi.Config{
+			Address: c.Consul.Host,
+			Scheme:  c.Consul.Scheme,
+			Token:   c.Consul.Token,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return NewConsulAllocator(client, c.Key, ttl), nil
+	default:
+		return nil, nil
+	}
+}
+
+// leaseOwner returns a value that identifies this process as the holder of a lease.
+func leaseOwner() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), stringx.Randn(8))
+}
@@ -0,0 +1,109 @@
+package snake
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	consulApi "github.com/hashicorp/consul/api"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// ConsulAllocator is a WorkerIDAllocator that leases worker ids as consul KV locks.
+// Each slot is the key "<prefix>/<workerID>" acquired with a session whose ttl is the lease ttl;
+// the session is created with the delete behavior so an expired lease frees the slot.
+type ConsulAllocator struct {
+	leaseState
+	client    *consulApi.Client
+	prefix    string
+	ttl       time.Duration
+	owner     string
+	sessionID string
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+	renewDone chan struct{}
+}
+
+// NewConsulAllocator returns a ConsulAllocator storing slots under prefix.
+func NewConsulAllocator(client *consulApi.Client, prefix string, ttl time.Duration) *ConsulAllocator {
+	if ttl < defaultAllocatorTTL*time.Second {
+		// consul rejects session ttl below 10s
+		ttl = defaultAllocatorTTL * time.Second
+	}
+
+	return &ConsulAllocator{
+		leaseState: newLeaseState(),
+		client:     client,
+		prefix:     prefix,
+		ttl:        ttl,
+		owner:      leaseOwner(),
+		stopCh:     make(chan struct{}),
+		renewDone:  make(chan struct{}),
+	}
+}
+
+// Acquire claims the lowest free worker id in [0, maxWorkerID] and starts renewing it.
+func (a *ConsulAllocator) Acquire(maxWorkerID int64) (int64, error) {
+	sessionID, _, err := a.client.Session().Create(&consulApi.SessionEntry{
+		Name:     a.owner,
+		TTL:      a.ttl.String(),
+		Behavior: consulApi.SessionBehaviorDelete,
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	for slot := int64(0); slot <= maxWorkerID; slot++ {
+		ok, _, err := a.client.KV().Acquire(&consulApi.KVPair{
+			Key:     fmt.Sprintf("%s/%d", a.prefix, slot),
+			Value:   []byte(a.owner),
+			Session: sessionID,
+		}, nil)
+		if err != nil {
+			_, destroyErr := a.client.Session().Destroy(sessionID, nil)
+			return 0, errors.Join(err, destroyErr)
+		}
+		if !ok {
+			continue
+		}
+
+		a.sessionID = sessionID
+		go a.renew()
+		logx.Infof("snake worker id %d acquired, key: %s/%d", slot, a.prefix, slot)
+		return slot, nil
+	}
+
+	_, destroyErr := a.client.Session().Destroy(sessionID, nil)
+	return 0, errors.Join(fmt.Errorf("no free worker id in [0, %d] under %s", maxWorkerID, a.prefix), destroyErr)
+}
+
+// Release stops renewing and destroys the session, which deletes the slot.
+func (a *ConsulAllocator) Release() error {
+	a.stopOnce.Do(func() {
+		close(a.stopCh)
+	})
+	if len(a.sessionID) == 0 {
+		return nil
+	}
+
+	<-a.renewDone
+	// RenewPeriodic destroys the session on stop but drops the error, destroy it again to report it
+	_, err := a.client.Session().Destroy(a.sessionID, nil)
+	return err
+}
+
+// renew keeps the session alive until released; the session is destroyed on release.
+func (a *ConsulAllocator) renew() {
+	defer close(a.renewDone)
+
+	err := a.client.Session().RenewPeriodic(a.ttl.String(), a.sessionID, nil, a.stopCh)
+	select {
+	case <-a.stopCh:
+		return
+	default:
+	}
+
+	logx.Errorf("snake worker id lease lost, session: %s, error: %v", a.sessionID, err)
+	a.markLost()
+}
@@ -0,0 +1,131 @@
+package snake
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+const (
+	redisRenewScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("EXPIRE", KEYS[1], ARGV[2])
+else
+    return 0
+end`
+	redisReleaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+else
+    return 0
+end`
+)
+
+// RedisAllocator is a WorkerIDAllocator that leases worker ids as redis keys.
+// Each slot is stored as "<prefix>:<workerID>" with the owner as value and the lease ttl as expiry.
+type RedisAllocator struct {
+	leaseState
+	store    *redis.Redis
+	prefix   string
+	ttl      time.Duration
+	owner    string
+	key      string
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRedisAllocator returns a RedisAllocator storing slots under prefix.
+func NewRedisAllocator(store *redis.Redis, prefix string, ttl time.Duration) *RedisAllocator {
+	if ttl < time.Second {
+		ttl = defaultAllocatorTTL * time.Second
+	}
+
+	return &RedisAllocator{
+		leaseState: newLeaseState(),
+		store:      store,
+		prefix:     prefix,
+		ttl:        ttl,
+		owner:      leaseOwner(),
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Acquire claims the lowest free worker id in [0, maxWorkerID] and starts renewing it.
+func (a *RedisAllocator) Acquire(maxWorkerID int64) (int64, error) {
+	for slot := int64(0); slot <= maxWorkerID; slot++ {
+		key := fmt.Sprintf("%s:%d", a.prefix, slot)
+		start := time.Now()
+		ok, err := a.store.SetnxEx(key, a.owner, a.ttlSeconds())
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+
+		a.key = key
+		go a.renew(start)
+		logx.Infof("snake worker id %d acquired, key: %s", slot, key)
+		return slot, nil
+	}
+
+	return 0, fmt.Errorf("no free worker id in [0, %d] under %s", maxWorkerID, a.prefix)
+}
+
+// Release stops renewing and deletes the slot if it is still owned by this allocator.
+func (a *RedisAllocator) Release() error {
+	a.stopOnce.Do(func() {
+		close(a.stopCh)
+	})
+	if len(a.key) == 0 {
+		return nil
+	}
+
+	_, err := a.store.Eval(redisReleaseScript, []string{a.key}, a.owner)
+	return err
+}
+
+// renew extends the lease every ttl/3 until released, retrying sooner after a failure.
+// The lease is marked lost when the key is taken over, or once it has not been renewed for
+// ttl - ttl/3, so generation stops before the key can expire and be claimed by another process.
+func (a *RedisAllocator) renew(acquired time.Time) {
+	ticker := time.NewTicker(a.ttl / 3)
+	defer ticker.Stop()
+
+	safeTTL := a.ttl - a.ttl/3
+	deadline := time.NewTimer(safeTTL - time.Since(acquired))
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-a.stopCh:
+			return
+		case <-deadline.C:
+			logx.Errorf("snake worker id lease not renewed in time, key: %s", a.key)
+			a.markLost()
+			return
+		case <-ticker.C:
+			// the key expires ttl after redis runs the script, which is no earlier than start
+			start := time.Now()
+			resp, err := a.store.Eval(redisRenewScript, []string{a.key}, a.owner, a.ttlSeconds())
+			if err != nil {
+				logx.Errorf("snake worker id lease renew failed, key: %s, error: %v", a.key, err)
+				ticker.Reset(a.ttl / 12)
+				continue
+			}
+
+			if n, ok := resp.(int64); !ok || n != 1 {
+				logx.Errorf("snake worker id lease lost, key: %s", a.key)
+				a.markLost()
+				return
+			}
+			deadline.Reset(safeTTL - time.Since(start))
+			ticker.Reset(a.ttl / 3)
+		}
+	}
+}
+
+func (a *RedisAllocator) ttlSeconds() int {
+	return int(a.ttl / time.Second)
+}
@@ -0,0 +1,403 @@
+package snake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	consulApi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+func TestAllocatorConfValidate(t *testing.T) {
+	c := AllocatorConf{}
+	assert.NoError(t, c.Validate())
+
+	c = AllocatorConf{Type: AllocatorTypeRedis}
+	assert.EqualError(t, c.Validate(), "empty allocator key")
+
+	c = AllocatorConf{Type: AllocatorTypeRedis, Key: "snake"}
+	assert.Error(t, c.Validate())
+
+	c = AllocatorConf{Type: AllocatorTypeRedis, Key: "snake", Redis: redis.RedisConf{Host: "localhost:6379"}}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, defaultAllocatorTTL, c.TTL)
+	assert.Equal(t, redis.NodeType, c.Redis.Type)
+
+	c = AllocatorConf{Type: AllocatorTypeConsul, Key: "snake"}
+	assert.EqualError(t, c.Validate(), "empty allocator consul hosts")
+
+	c = AllocatorConf{Type: AllocatorTypeConsul, Key: "snake", Consul: ConsulAllocatorConf{Host: "localhost:8500"}}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, defaultConsulScheme, c.Consul.Scheme)
+
+	c = AllocatorConf{Type: "etcd", Key: "snake"}
+	assert.EqualError(t, c.Validate(), "unknown allocator type: etcd")
+}
+
+func TestMemoryAllocator(t *testing.T) {
+	pool := NewMemoryAllocatorPool()
+	a1 := pool.NewAllocator()
+	a2 := pool.NewAllocator()
+	a3 := pool.NewAllocator()
+
+	id1, err := a1.Acquire(1)
+	require.NoError(t, err)
+	id2, err := a2.Acquire(1)
+	require.NoError(t, err)
+	assert.NotEqual(t, id1, id2)
+
+	_, err = a3.Acquire(1)
+	assert.Error(t, err)
+
+	require.NoError(t, a1.Release())
+	id3, err := a3.Acquire(1)
+	require.NoError(t, err)
+	assert.Equal(t, id1, id3)
+}
+
+func TestNewSnake_WithMemoryAllocator(t *testing.T) {
+	pool := NewMemoryAllocatorPool()
+	conf := Conf{WorkerIDBits: 2, SequenceBits: 12}
+
+	s1, err := NewSnake(conf, WithWorkerIDAllocator(pool.NewAllocator()))
+	require.NoError(t, err)
+	s2, err := NewSnake(conf, WithWorkerIDAllocator(pool.NewAllocator()))
+	require.NoError(t, err)
+
+	id1, err := s1.Generator()
+	require.NoError(t, err)
+	id2, err := s2.Generator()
+	require.NoError(t, err)
+	assert.NotEqual(t, s1.GetWorkerIDFromID(id1), s2.GetWorkerIDFromID(id2))
+
+	require.NoError(t, s1.Close())
+	s3, err := NewSnake(conf, WithWorkerIDAllocator(pool.NewAllocator()))
+	require.NoError(t, err)
+	id3, err := s3.Generator()
+	require.NoError(t, err)
+	assert.Equal(t, s1.GetWorkerIDFromID(id1), s3.GetWorkerIDFromID(id3))
+}
+
+func TestNewSnake_AllocatorExhausted(t *testing.T) {
+	pool := NewMemoryAllocatorPool()
+	conf := Conf{WorkerIDBits: 1, SequenceBits: 12}
+
+	for i := 0; i < 2; i++ {
+		_, err := NewSnake(conf, WithWorkerIDAllocator(pool.NewAllocator()))
+		require.NoError(t, err)
+	}
+	_, err := NewSnake(conf, WithWorkerIDAllocator(pool.NewAllocator()))
+	assert.Error(t, err)
+}
+
+func TestNewSnake_ExplicitWorkerIDSkipsAllocator(t *testing.T) {
+	pool := NewMemoryAllocatorPool()
+	allocator := pool.NewAllocator()
+
+	s, err := NewSnake(Conf{WorkerID: 7}, WithWorkerIDAllocator(allocator))
+	require.NoError(t, err)
+	id, err := s.Generator()
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), s.GetWorkerIDFromID(id))
+	assert.Empty(t, pool.slots)
+}
+
+func TestGenerator_LeaseLost(t *testing.T) {
+	allocator := NewMemoryAllocatorPool().NewAllocator()
+	s, err := NewSnake(Conf{}, WithWorkerIDAllocator(allocator))
+	require.NoError(t, err)
+
+	_, err = s.Generator()
+	require.NoError(t, err)
+
+	allocator.Expire()
+	_, err = s.Generator()
+	assert.ErrorIs(t, err, ErrWorkerIDLeaseLost)
+}
+
+func TestGenerator_Closed(t *testing.T) {
+	pool := NewMemoryAllocatorPool()
+	s1, err := NewSnake(Conf{}, WithWorkerIDAllocator(pool.NewAllocator()))
+	require.NoError(t, err)
+	require.NoError(t, s1.Close())
+
+	// the released worker id is handed to another snake, the closed one must stop minting
+	s2, err := NewSnake(Conf{}, WithWorkerIDAllocator(pool.NewAllocator()))
+	require.NoError(t, err)
+	defer s2.Close()
+	assert.Equal(t, s1.(*CommonSnake).workerID, s2.(*CommonSnake).workerID)
+
+	_, err = s1.Generator()
+	assert.ErrorIs(t, err, ErrSnakeClosed)
+	_, err = s1.GenerateBatch(2)
+	assert.ErrorIs(t, err, ErrSnakeClosed)
+	_, err = s2.Generator()
+	assert.NoError(t, err)
+}
+
+func TestClose_WaitsForInFlightClaims(t *testing.T) {
+	pool := NewMemoryAllocatorPool()
+	s, err := NewSnake(Conf{}, WithWorkerIDAllocator(pool.NewAllocator()))
+	require.NoError(t, err)
+	cs := s.(*CommonSnake)
+
+	// a claim in flight holds the lock, the lease must outlive it
+	cs.mu.Lock()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Close()
+	}()
+	select {
+	case <-done:
+		t.Fatal("Close returned during an in-flight claim")
+	case <-time.After(50 * time.Millisecond):
+	}
+	pool.lock.Lock()
+	assert.Len(t, pool.slots, 1)
+	pool.lock.Unlock()
+	cs.mu.Unlock()
+
+	require.NoError(t, <-done)
+	assert.Empty(t, pool.slots)
+	_, err = s.Generator()
+	assert.ErrorIs(t, err, ErrSnakeClosed)
+}
+
+func TestRedisAllocator(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := redis.New(mr.Addr())
+
+	a1 := NewRedisAllocator(store, "snake", 3*time.Second)
+	a2 := NewRedisAllocator(store, "snake", 3*time.Second)
+
+	id1, err := a1.Acquire(1)
+	require.NoError(t, err)
+	id2, err := a2.Acquire(1)
+	require.NoError(t, err)
+	assert.NotEqual(t, id1, id2)
+
+	_, err = NewRedisAllocator(store, "snake", 3*time.Second).Acquire(1)
+	assert.Error(t, err)
+
+	require.NoError(t, a1.Release())
+	assert.False(t, mr.Exists("snake:0"))
+	require.NoError(t, a2.Release())
+}
+
+func TestRedisAllocator_Renew(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := redis.New(mr.Addr())
+
+	a := NewRedisAllocator(store, "snake", 3*time.Second)
+	_, err := a.Acquire(0)
+	require.NoError(t, err)
+	defer a.Release()
+
+	mr.SetTTL("snake:0", time.Second)
+	assert.Eventually(t, func() bool {
+		return mr.TTL("snake:0") > time.Second
+	}, 3*time.Second, 50*time.Millisecond)
+}
+
+func TestRedisAllocator_LeaseLost(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := redis.New(mr.Addr())
+
+	a := NewRedisAllocator(store, "snake", 3*time.Second)
+	_, err := a.Acquire(0)
+	require.NoError(t, err)
+	defer a.Release()
+
+	// another process took over the slot after our lease expired
+	require.NoError(t, mr.Set("snake:0", "someone-else"))
+	select {
+	case <-a.Lost():
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected lease to be lost")
+	}
+	assert.Equal(t, "someone-else", mustGet(t, mr, "snake:0"))
+}
+
+func TestRedisAllocator_LostBeforeExpiry(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := redis.New(mr.Addr())
+
+	ttl := 3 * time.Second
+	a := NewRedisAllocator(store, "snake", ttl)
+	acquired := time.Now()
+	_, err := a.Acquire(0)
+	require.NoError(t, err)
+	defer a.Release()
+
+	// redis keeps failing, the lease must be given up before the key can expire
+	mr.SetError("unavailable")
+	defer mr.SetError("")
+	select {
+	case <-a.Lost():
+		assert.Less(t, time.Since(acquired), ttl)
+	case <-time.After(ttl):
+		t.Fatal("expected lease to be lost before it expires")
+	}
+}
+
+func TestNewSnake_RedisAllocatorConf(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	s, err := NewSnake(Conf{
+		Allocator: AllocatorConf{
+			Type:  AllocatorTypeRedis,
+			Key:   "snake",
+			Redis: redis.RedisConf{Host: mr.Addr(), Type: redis.NodeType},
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, mr.Exists("snake:0"))
+
+	require.NoError(t, s.Close())
+	assert.False(t, mr.Exists("snake:0"))
+}
+
+func mustGet(t *testing.T, mr *miniredis.Miniredis, key string) string {
+	v, err := mr.Get(key)
+	require.NoError(t, err)
+	return v
+}
+
+// fakeConsul is a minimal stand-in for the consul session and KV lock endpoints.
+type fakeConsul struct {
+	lock        sync.Mutex
+	sessions    map[string]bool
+	holders     map[string]string
+	renewFail   bool
+	destroyFail bool
+	nextID      int
+}
+
+func newFakeConsul(t *testing.T) (*fakeConsul, *consulApi.Client) {
+	f := &fakeConsul{sessions: map[string]bool{}, holders: map[string]string{}}
+	srv := httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(srv.Close)
+
+	client, err := consulApi.NewClient(&consulApi.Config{Address: strings.TrimPrefix(srv.URL, "http://")})
+	require.NoError(t, err)
+	return f, client
+}
+
+func (f *fakeConsul) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	switch {
+	case r.URL.Path == "/v1/session/create":
+		f.nextID++
+		id := "session-" + strconv.Itoa(f.nextID)
+		f.sessions[id] = true
+		_ = json.NewEncoder(w).Encode(map[string]string{"ID": id})
+	case strings.HasPrefix(r.URL.Path, "/v1/session/renew/"):
+		id := strings.TrimPrefix(r.URL.Path, "/v1/session/renew/")
+		if f.renewFail || !f.sessions[id] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]map[string]string{{"ID": id, "TTL": "10s"}})
+	case strings.HasPrefix(r.URL.Path, "/v1/session/destroy/"):
+		if f.destroyFail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/v1/session/destroy/")
+		delete(f.sessions, id)
+		for key, holder := range f.holders {
+			if holder == id {
+				delete(f.holders, key)
+			}
+		}
+		_, _ = w.Write([]byte("true"))
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/"):
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		session := r.URL.Query().Get("acquire")
+		if _, ok := f.holders[key]; ok {
+			_, _ = w.Write([]byte("false"))
+			return
+		}
+		f.holders[key] = session
+		_, _ = w.Write([]byte("true"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeConsul) holder(key string) (string, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	holder, ok := f.holders[key]
+	return holder, ok
+}
+
+func TestConsulAllocator(t *testing.T) {
+	f, client := newFakeConsul(t)
+
+	a1 := NewConsulAllocator(client, "snake", 0)
+	a2 := NewConsulAllocator(client, "snake", 0)
+
+	id1, err := a1.Acquire(1)
+	require.NoError(t, err)
+	id2, err := a2.Acquire(1)
+	require.NoError(t, err)
+	assert.NotEqual(t, id1, id2)
+
+	_, err = NewConsulAllocator(client, "snake", 0).Acquire(1)
+	assert.Error(t, err)
+
+	require.NoError(t, a1.Release())
+	_, ok := f.holder("snake/0")
+	assert.False(t, ok)
+	require.NoError(t, a2.Release())
+}
+
+func TestConsulAllocator_ReleaseError(t *testing.T) {
+	f, client := newFakeConsul(t)
+
+	a := NewConsulAllocator(client, "snake", 0)
+	_, err := a.Acquire(0)
+	require.NoError(t, err)
+
+	f.lock.Lock()
+	f.destroyFail = true
+	f.lock.Unlock()
+
+	// the lock is still held until the session ttl expires, Release must say so
+	assert.Error(t, a.Release())
+	_, ok := f.holder("snake/0")
+	assert.True(t, ok)
+}
+
+func TestConsulAllocator_LeaseLost(t *testing.T) {
+	f, client := newFakeConsul(t)
+
+	a := NewConsulAllocator(client, "snake", 0)
+	// renew every 50ms so the test does not wait for half of the 10s minimum ttl
+	a.ttl = 100 * time.Millisecond
+	_, err := a.Acquire(0)
+	require.NoError(t, err)
+	defer a.Release()
+
+	f.lock.Lock()
+	f.renewFail = true
+	f.lock.Unlock()
+
+	select {
+	case <-a.Lost():
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected lease to be lost")
+	}
+}
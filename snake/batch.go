@@ -0,0 +1,75 @@
+package snake
+
+import "fmt"
+
+// IDRange is a block of consecutive ids minted in one millisecond.
+type IDRange struct {
+	First int64 // first id of the range
+	Count int64 // number of ids in the range
+	step  int64 // distance between consecutive ids, the sequence sits above the worker id in the sonyflake layout
+}
+
+// Len returns the number of ids in r.
+func (r IDRange) Len() int {
+	return int(r.Count)
+}
+
+// ID returns the i-th id of r.
+func (r IDRange) ID(i int) int64 {
+	step := r.step
+	if step == 0 {
+		step = 1
+	}
+	return r.First + int64(i)*step
+}
+
+// IDs returns all ids of r.
+func (r IDRange) IDs() []int64 {
+	ids := make([]int64, r.Count)
+	for i := range ids {
+		ids[i] = r.ID(i)
+	}
+	return ids
+}
+
+// GenerateBatch generates n unique ids, claiming whole sequence ranges per millisecond
+// instead of paying one CAS loop per id.
+func (c *CommonSnake) GenerateBatch(n int) ([]int64, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("batch size %d must be positive", n)
+	}
+
+	ids := make([]int64, 0, n)
+	for len(ids) < n {
+		r, err := c.Reserve(n - len(ids))
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < r.Len(); i++ {
+			ids = append(ids, r.ID(i))
+		}
+	}
+
+	return ids, nil
+}
+
+// Reserve claims up to n consecutive ids of the current millisecond in one atomic step.
+// The returned range may be shorter than n when the millisecond's sequence space runs out,
+// callers wanting exactly n ids keep reserving until they are satisfied.
+func (c *CommonSnake) Reserve(n int) (IDRange, error) {
+	if n <= 0 {
+		return IDRange{}, fmt.Errorf("reserve size %d must be positive", n)
+	}
+
+	timestamp, sequence, count, err := c.nextSequence(int64(n))
+	if err != nil {
+		return IDRange{}, err
+	}
+
+	return IDRange{
+		First: c.composeID(timestamp, sequence),
+		Count: count,
+		step:  1 << c.sequenceShift,
+	}, nil
+}
@@ -0,0 +1,189 @@
+package snake
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateBatch(t *testing.T) {
+	s, err := NewSnake(Conf{WorkerID: 1})
+	require.NoError(t, err)
+
+	ids, err := s.GenerateBatch(10000)
+	require.NoError(t, err)
+	require.Len(t, ids, 10000)
+
+	for i := 1; i < len(ids); i++ {
+		assert.Greater(t, ids[i], ids[i-1], "batch ids must be strictly increasing")
+	}
+	for _, id := range ids {
+		assert.Equal(t, int64(1), s.GetWorkerIDFromID(id))
+	}
+
+	next, err := s.Generator()
+	require.NoError(t, err)
+	assert.Greater(t, next, ids[len(ids)-1])
+}
+
+func TestGenerateBatch_InvalidSize(t *testing.T) {
+	s, err := NewSnake(Conf{WorkerID: 1})
+	require.NoError(t, err)
+
+	_, err = s.GenerateBatch(0)
+	assert.Error(t, err)
+	_, err = s.Reserve(-1)
+	assert.Error(t, err)
+}
+
+func TestGenerateBatch_Concurrent(t *testing.T) {
+	s, err := NewSnake(Conf{WorkerID: 1, SequenceBits: 8})
+	require.NoError(t, err)
+
+	var (
+		mu    sync.Mutex
+		idSet = make(map[int64]bool)
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(batch bool) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				var ids []int64
+				if batch {
+					batchIDs, err := s.GenerateBatch(37)
+					assert.NoError(t, err)
+					ids = batchIDs
+				} else {
+					id, err := s.Generator()
+					assert.NoError(t, err)
+					ids = []int64{id}
+				}
+
+				mu.Lock()
+				for _, id := range ids {
+					if idSet[id] {
+						t.Errorf("Duplicate ID generated: %d", id)
+					}
+					idSet[id] = true
+				}
+				mu.Unlock()
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+}
+
+func TestReserve_SingleMillisecond(t *testing.T) {
+	s, err := NewSnake(Conf{WorkerID: 1, SequenceBits: 3})
+	require.NoError(t, err)
+
+	r, err := s.Reserve(100)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, r.Count, int64(8), "a range never spans more than one millisecond")
+
+	ids := r.IDs()
+	require.Len(t, ids, r.Len())
+	ts := s.GetTimestampFromID(ids[0])
+	for i, id := range ids {
+		assert.Equal(t, r.ID(i), id)
+		assert.Equal(t, ts, s.GetTimestampFromID(id))
+	}
+}
+
+func TestReserve_SameMillisecond(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	s, err := NewSnake(Conf{WorkerID: 1, SequenceBits: 4}, WithClock(clock))
+	require.NoError(t, err)
+	cs := s.(*CommonSnake)
+
+	atomic.StoreInt64(&cs.timestamp, clock.Now().UnixMilli())
+	atomic.StoreInt64(&cs.sequence, 5)
+
+	r, err := s.Reserve(100)
+	require.NoError(t, err)
+	_, _, first := s.ParseID(r.First)
+	assert.Equal(t, int64(6), first)
+	assert.Equal(t, int64(10), r.Count, "only the rest of the millisecond is claimed")
+	assert.Equal(t, cs.maxSequence, atomic.LoadInt64(&cs.sequence))
+}
+
+func TestReserve_ClockBackwards(t *testing.T) {
+	s, err := NewSnake(Conf{WorkerID: 1, TimeDifference: 1})
+	require.NoError(t, err)
+	atomic.StoreInt64(&s.(*CommonSnake).timestamp, time.Now().UnixMilli()+1000)
+
+	_, err = s.GenerateBatch(10)
+	assert.Error(t, err)
+}
+
+func BenchmarkGenerator(b *testing.B) {
+	s := MustNewSnake(Conf{WorkerID: 1})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = s.Generator()
+	}
+}
+
+func BenchmarkGenerateBatch(b *testing.B) {
+	s := MustNewSnake(Conf{WorkerID: 1})
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1000 {
+		_, _ = s.GenerateBatch(1000)
+	}
+}
+
+func BenchmarkGeneratorParallel(b *testing.B) {
+	s := MustNewSnake(Conf{WorkerID: 1})
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = s.Generator()
+		}
+	})
+}
+
+func BenchmarkReserveParallel(b *testing.B) {
+	s := MustNewSnake(Conf{WorkerID: 1})
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = s.Reserve(64)
+		}
+	})
+}
+
+func TestGenerateBatch_SonyflakeLayout(t *testing.T) {
+	s, err := NewSnake(Conf{Layout: LayoutSonyflake, WorkerID: 5})
+	require.NoError(t, err)
+
+	ids, err := s.GenerateBatch(600)
+	require.NoError(t, err)
+	require.Len(t, ids, 600)
+
+	seen := make(map[int64]bool, len(ids))
+	for i, id := range ids {
+		assert.False(t, seen[id], "duplicate id %d", id)
+		seen[id] = true
+		if i > 0 {
+			assert.Greater(t, id, ids[i-1], "batch ids must be strictly increasing")
+		}
+
+		timestamp, workerID, sequence := s.ParseID(id)
+		assert.Equal(t, int64(5), workerID, "worker id of id %d", id)
+		assert.Equal(t, s.(*CommonSnake).composeID(timestamp, sequence), id, "id %d must round-trip", id)
+	}
+
+	r, err := s.Reserve(3)
+	require.NoError(t, err)
+	for i, id := range r.IDs() {
+		_, workerID, _ := s.ParseID(id)
+		assert.Equal(t, int64(5), workerID)
+		assert.Equal(t, r.ID(i), id)
+	}
+}
@@ -0,0 +1,174 @@
+package snake
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+type (
+	// CheckpointStore persists the high-water timestamp of generated ids so a restarted
+	// process does not reissue ids after the clock moved backwards.
+	CheckpointStore interface {
+		// Load returns the persisted timestamp in milliseconds, 0 if nothing was persisted.
+		Load() (int64, error)
+		// Save persists timestamp in milliseconds.
+		Save(timestamp int64) error
+	}
+
+	// FileCheckpointStore is a CheckpointStore backed by a local file.
+	FileCheckpointStore struct {
+		path string
+	}
+
+	// RedisCheckpointStore is a CheckpointStore backed by a redis key.
+	RedisCheckpointStore struct {
+		store *redis.Redis
+		key   string
+	}
+)
+
+// NewFileCheckpointStore returns a FileCheckpointStore writing to path.
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+// Load reads the timestamp from the file, 0 if the file does not exist.
+func (s *FileCheckpointStore) Load() (int64, error) {
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// Save writes the timestamp to a temporary file and renames it over the checkpoint,
+// so a crash never leaves a partially written checkpoint behind.
+func (s *FileCheckpointStore) Save(timestamp int64) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.WriteString(strconv.FormatInt(timestamp, 10)); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// NewRedisCheckpointStore returns a RedisCheckpointStore writing to key.
+func NewRedisCheckpointStore(store *redis.Redis, key string) *RedisCheckpointStore {
+	return &RedisCheckpointStore{store: store, key: key}
+}
+
+// Load reads the timestamp from redis, 0 if the key does not exist.
+func (s *RedisCheckpointStore) Load() (int64, error) {
+	val, err := s.store.Get(s.key)
+	if err != nil {
+		return 0, err
+	}
+	if len(val) == 0 {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(val, 10, 64)
+}
+
+// Save writes the timestamp to redis.
+func (s *RedisCheckpointStore) Save(timestamp int64) error {
+	return s.store.Set(s.key, strconv.FormatInt(timestamp, 10))
+}
+
+// newCheckpointStore builds the CheckpointStore described by c, nil if none is configured.
+// The redis key is suffixed with workerID since each worker id has its own high-water timestamp.
+func newCheckpointStore(c CheckpointConf, workerID int64) (CheckpointStore, error) {
+	switch c.Type {
+	case CheckpointTypeFile:
+		return NewFileCheckpointStore(c.Path), nil
+	case CheckpointTypeRedis:
+		store, err := redis.NewRedis(c.Redis)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisCheckpointStore(store, fmt.Sprintf("%s:%d", c.Key, workerID)), nil
+	default:
+		return nil, nil
+	}
+}
+
+// restoreCheckpoint waits until the clock passes the time unit of the persisted checkpoint, or fails
+// if that takes longer than MaxWait, then persists a new checkpoint ahead of now.
+func (c *CommonSnake) restoreCheckpoint() error {
+	checkpoint, err := c.checkpoint.Load()
+	if err != nil {
+		return fmt.Errorf("load snake checkpoint failed: %w", err)
+	}
+
+	if checkpoint > 0 {
+		// ids of the checkpoint's time unit may already be issued, wait for the next one
+		checkpoint = c.truncateTime(checkpoint)
+	}
+
+	if timeDifference := checkpoint + c.snakeConf.TimeUnit - c.clock.Now().UnixMilli(); timeDifference > 0 {
+		if timeDifference > c.snakeConf.Checkpoint.MaxWait {
+			return fmt.Errorf("clock is behind the last checkpoint, refusing to start for %d milliseconds", timeDifference)
+		}
+
+		logx.Infof("snake clock is behind the last checkpoint, waiting %d milliseconds", timeDifference)
+		c.clock.Sleep(time.Duration(timeDifference) * time.Millisecond)
+	}
+	// every id issued before the restart is at or below the checkpoint
+	atomic.StoreInt64(&c.timestamp, checkpoint)
+
+	if err = c.checkpoint.Save(c.checkpointCeiling()); err != nil {
+		return fmt.Errorf("save snake checkpoint failed: %w", err)
+	}
+
+	go c.persistCheckpoint()
+	return nil
+}
+
+// persistCheckpoint refreshes the checkpoint every Interval until the snake is closed.
+func (c *CommonSnake) persistCheckpoint() {
+	ticker := time.NewTicker(time.Duration(c.snakeConf.Checkpoint.Interval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+			if err := c.checkpoint.Save(c.checkpointCeiling()); err != nil {
+				logx.Errorf("save snake checkpoint failed: %v", err)
+			}
+		}
+	}
+}
+
+// checkpointCeiling is persisted two intervals ahead of now, so the checkpoint covers
+// every id issued until the next refresh even if the process crashes in between.
+func (c *CommonSnake) checkpointCeiling() int64 {
+	return c.clock.Now().UnixMilli() + 2*c.snakeConf.Checkpoint.Interval
+}
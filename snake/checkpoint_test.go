@@ -0,0 +1,191 @@
+package snake
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+type failingCheckpointStore struct{}
+
+func (failingCheckpointStore) Load() (int64, error) { return 0, errors.New("boom") }
+func (failingCheckpointStore) Save(int64) error     { return errors.New("boom") }
+
+func TestCheckpointConfValidate(t *testing.T) {
+	c := CheckpointConf{}
+	assert.NoError(t, c.Validate())
+
+	c = CheckpointConf{Type: CheckpointTypeFile}
+	assert.EqualError(t, c.Validate(), "empty checkpoint path")
+
+	c = CheckpointConf{Type: CheckpointTypeFile, Path: "snake.checkpoint"}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, int64(defaultCheckpointInterval), c.Interval)
+	assert.Equal(t, int64(defaultCheckpointMaxWait), c.MaxWait)
+
+	c = CheckpointConf{Type: CheckpointTypeRedis}
+	assert.EqualError(t, c.Validate(), "empty checkpoint key")
+
+	c = CheckpointConf{Type: CheckpointTypeRedis, Key: "snake", Redis: redis.RedisConf{Host: "localhost:6379"}}
+	assert.NoError(t, c.Validate())
+
+	c = CheckpointConf{Type: "etcd"}
+	assert.EqualError(t, c.Validate(), "unknown checkpoint type: etcd")
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snake.checkpoint")
+	store := NewFileCheckpointStore(path)
+
+	ts, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), ts)
+
+	require.NoError(t, store.Save(1717171717171))
+	ts, err = store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1717171717171), ts)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files must be cleaned up")
+
+	require.NoError(t, os.WriteFile(path, []byte("garbage"), 0o644))
+	_, err = store.Load()
+	assert.Error(t, err)
+}
+
+func TestRedisCheckpointStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := NewRedisCheckpointStore(redis.New(mr.Addr()), "snake:1")
+
+	ts, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), ts)
+
+	require.NoError(t, store.Save(1717171717171))
+	ts, err = store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1717171717171), ts)
+}
+
+func TestNewSnake_CheckpointPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snake.checkpoint")
+	conf := Conf{
+		WorkerID:   1,
+		Checkpoint: CheckpointConf{Type: CheckpointTypeFile, Path: path, Interval: 20},
+	}
+
+	s, err := NewSnake(conf)
+	require.NoError(t, err)
+
+	ceiling, err := NewFileCheckpointStore(path).Load()
+	require.NoError(t, err)
+	assert.Greater(t, ceiling, time.Now().UnixMilli(), "checkpoint is written ahead of now")
+
+	id, err := s.Generator()
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	last, err := NewFileCheckpointStore(path).Load()
+	require.NoError(t, err)
+	assert.Equal(t, s.GetTimestampFromID(id), last, "close persists the real high-water timestamp")
+}
+
+func TestNewSnake_CheckpointWait(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snake.checkpoint")
+	checkpoint := time.Now().UnixMilli() + 50
+	require.NoError(t, NewFileCheckpointStore(path).Save(checkpoint))
+
+	s, err := NewSnake(Conf{
+		WorkerID:   1,
+		Checkpoint: CheckpointConf{Type: CheckpointTypeFile, Path: path, MaxWait: 1000},
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	id, err := s.Generator()
+	require.NoError(t, err)
+	assert.Greater(t, s.GetTimestampFromID(id), checkpoint)
+}
+
+func TestNewSnake_CheckpointRefuse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snake.checkpoint")
+	checkpoint := time.Now().UnixMilli() + time.Hour.Milliseconds()
+	require.NoError(t, NewFileCheckpointStore(path).Save(checkpoint))
+
+	pool := NewMemoryAllocatorPool()
+	_, err := NewSnake(Conf{
+		Checkpoint: CheckpointConf{Type: CheckpointTypeFile, Path: path, MaxWait: 10},
+	}, WithWorkerIDAllocator(pool.NewAllocator()))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refusing to start")
+	assert.Empty(t, pool.slots, "worker id is released when start is refused")
+
+	persisted, err := NewFileCheckpointStore(path).Load()
+	require.NoError(t, err)
+	assert.Equal(t, checkpoint, persisted, "refused start must not overwrite the checkpoint")
+}
+
+func TestNewSnake_CheckpointLoadError(t *testing.T) {
+	_, err := NewSnake(Conf{WorkerID: 1}, WithCheckpointStore(failingCheckpointStore{}))
+	assert.Error(t, err)
+}
+
+func TestNewSnake_RedisCheckpointConf(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	s, err := NewSnake(Conf{
+		WorkerID: 3,
+		Checkpoint: CheckpointConf{
+			Type:  CheckpointTypeRedis,
+			Key:   "snake:checkpoint",
+			Redis: redis.RedisConf{Host: mr.Addr(), Type: redis.NodeType},
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, mr.Exists("snake:checkpoint:3"))
+	require.NoError(t, s.Close())
+}
+
+func TestPersistCheckpoint_Refresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snake.checkpoint")
+	s, err := NewSnake(Conf{
+		WorkerID:   1,
+		Checkpoint: CheckpointConf{Type: CheckpointTypeFile, Path: path, Interval: 10},
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	first, err := NewFileCheckpointStore(path).Load()
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		ts, err := NewFileCheckpointStore(path).Load()
+		return err == nil && ts > first
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestNewSnake_CheckpointFakeClock(t *testing.T) {
+	start := time.UnixMilli(1735689600000)
+	clock := NewFakeClock(start)
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "snake.checkpoint"))
+	require.NoError(t, store.Save(start.UnixMilli()+50))
+
+	s, err := NewSnake(Conf{WorkerID: 1}, WithClock(clock), WithCheckpointStore(store))
+	require.NoError(t, err)
+	defer s.Close()
+
+	// NewSnake slept on the fake clock until the time unit after the checkpoint
+	assert.Equal(t, start.Add(51*time.Millisecond), clock.Now())
+
+	id, err := s.Generator()
+	require.NoError(t, err)
+	assert.Equal(t, start.UnixMilli()+51, s.GetTimestampFromID(id))
+}
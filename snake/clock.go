@@ -0,0 +1,105 @@
+package snake
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// Clock is the time source of a snake.
+	Clock interface {
+		// Now returns the current time.
+		Now() time.Time
+		// Sleep pauses the calling goroutine for at least d.
+		Sleep(d time.Duration)
+	}
+
+	// monotonicClock derives wall time from the monotonic reading taken at creation,
+	// so NTP steps and manual clock changes do not move it.
+	monotonicClock struct {
+		anchor time.Time
+	}
+
+	// wallClock follows the system wall clock.
+	wallClock struct{}
+
+	// FakeClock is a Clock driven by hand, useful in tests. Sleep advances it instead of blocking.
+	FakeClock struct {
+		lock sync.Mutex
+		now  time.Time
+	}
+)
+
+// NewMonotonicClock returns a Clock anchored at the current wall time that only moves forward
+// with the monotonic clock. It is the default Clock.
+func NewMonotonicClock() Clock {
+	return &monotonicClock{anchor: time.Now()}
+}
+
+// Now returns the anchor plus the monotonic time elapsed since it.
+func (c *monotonicClock) Now() time.Time {
+	return c.anchor.Add(time.Since(c.anchor))
+}
+
+// Sleep pauses the calling goroutine for at least d.
+func (c *monotonicClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// NewWallClock returns a Clock following the system wall clock.
+func NewWallClock() Clock {
+	return wallClock{}
+}
+
+// Now returns time.Now().
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep pauses the calling goroutine for at least d.
+func (wallClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+// Sleep advances the clock by d without blocking.
+func (c *FakeClock) Sleep(d time.Duration) {
+	c.Add(d)
+}
+
+// Add moves the clock by d, a negative d simulates a clock rollback.
+func (c *FakeClock) Add(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Set sets the clock to now.
+func (c *FakeClock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = now
+}
+
+// newClock builds the Clock named by clockType.
+func newClock(clockType string) Clock {
+	if clockType == ClockWall {
+		return NewWallClock()
+	}
+
+	return NewMonotonicClock()
+}
@@ -0,0 +1,45 @@
+package snake
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonotonicClock(t *testing.T) {
+	clock := NewMonotonicClock()
+
+	now := clock.Now()
+	assert.WithinDuration(t, time.Now(), now, 10*time.Millisecond)
+
+	clock.Sleep(2 * time.Millisecond)
+	assert.GreaterOrEqual(t, clock.Now().Sub(now), 2*time.Millisecond)
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.UnixMilli(1735689600000)
+	clock := NewFakeClock(start)
+	assert.Equal(t, start, clock.Now())
+
+	clock.Sleep(time.Second)
+	assert.Equal(t, start.Add(time.Second), clock.Now())
+
+	clock.Add(-2 * time.Second)
+	assert.Equal(t, start.Add(-time.Second), clock.Now())
+
+	clock.Set(start)
+	assert.Equal(t, start, clock.Now())
+}
+
+func TestConfClock(t *testing.T) {
+	c := Conf{WorkerID: 1}
+	require.NoError(t, c.Validate())
+	assert.Equal(t, ClockMonotonic, c.Clock)
+	assert.IsType(t, &monotonicClock{}, newClock(c.Clock))
+	assert.IsType(t, wallClock{}, newClock(ClockWall))
+
+	c.Clock = "ntp"
+	assert.Error(t, c.Validate())
+}
@@ -1,27 +1,108 @@
 package snake
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
 
 const (
-	defaultWorkerIDBits         = 10
-	defaultSequenceBits         = 12
-	defaultEpoch          int64 = 1704067200000
-	defaultTimeDifference       = 5
-	envPodIP                    = "POD_IP"
+	defaultWorkerIDBits             = 10
+	defaultSequenceBits             = 12
+	defaultEpoch              int64 = 1704067200000
+	defaultTimeUnit           int64 = 1
+	defaultTimeDifference           = 5
+	defaultAllocatorTTL             = 10
+	defaultConsulScheme             = "http"
+	defaultCheckpointInterval       = 1000
+	defaultCheckpointMaxWait        = 5000
+	envPodIP                        = "POD_IP"
+
+	sonyflakeEpoch         int64 = 1409529600000 // 2014-09-01 00:00:00 UTC
+	sonyflakeTimeUnit      int64 = 10
+	sonyflakeSequenceBits        = 8
+	sonyflakeMachineIDBits       = 16
+
+	LayoutSnowflake = "snowflake" // timestamp | datacenter id | worker id | sequence
+	LayoutSonyflake = "sonyflake" // timestamp | sequence | machine id, in 10ms units
+
+	AllocatorTypeRedis  = "redis"
+	AllocatorTypeConsul = "consul"
+
+	CheckpointTypeFile  = "file"
+	CheckpointTypeRedis = "redis"
+
+	ClockMonotonic = "monotonic" // wall time derived from the monotonic clock, ignores clock steps
+	ClockWall      = "wall"      // system wall clock
 )
 
 type Conf struct {
-	WorkerIDBits   uint8 `json:",default=10"`            // worker id bits, suggest not exceed 10 bits
-	SequenceBits   uint8 `json:",default=12"`            // sequence number bits, suggest not exceed 12 bits
-	Epoch          int64 `json:",default=1704067200000"` // epoch timestamp in milliseconds
-	TimeDifference int64 `json:",default=5"`             // max time difference in milliseconds
-	WorkerID       int64 `json:",optional"`
+	Layout         string         `json:",default=snowflake,options=snowflake|sonyflake"` // id layout, sonyflake ignores the bits and TimeUnit settings
+	WorkerIDBits   uint8          `json:",default=10"`                                    // worker id bits, suggest not exceed 10 bits
+	SequenceBits   uint8          `json:",default=12"`                                    // sequence number bits, suggest not exceed 12 bits
+	DatacenterBits uint8          `json:",optional"`                                      // datacenter id bits, 0 disables the datacenter field
+	TimeUnit       int64          `json:",default=1"`                                     // timestamp resolution in milliseconds, e.g. 1, 10, 1000
+	Epoch          int64          `json:",optional"`                                      // epoch timestamp in milliseconds, defaults to 2024-01-01 (2014-09-01 for sonyflake)
+	TimeDifference int64          `json:",default=5"`                                     // max time difference in milliseconds
+	Clock          string         `json:",default=monotonic,options=monotonic|wall"`      // time source, WithClock takes precedence
+	WorkerID       int64          `json:",optional"`
+	DatacenterID   int64          `json:",optional"`
+	Allocator      AllocatorConf  `json:",optional"` // lease-based worker id allocation, used when WorkerID is 0
+	Checkpoint     CheckpointConf `json:",optional"` // persistent high-water timestamp, guards against clock rollback across restarts
+}
+
+// AllocatorConf is the config of the lease-based worker id allocator.
+// Type is the allocator backend. example: "redis", "consul"
+// Key is the key prefix of the worker id slots. example: "snake/order-api"
+// TTL is the lease ttl in seconds. example: 10
+type AllocatorConf struct {
+	Type   string              `json:",optional,options=redis|consul"`
+	Key    string              `json:",optional"`
+	TTL    int                 `json:",default=10"`
+	Redis  redis.RedisConf     `json:",optional"`
+	Consul ConsulAllocatorConf `json:",optional"`
+}
+
+// ConsulAllocatorConf is the consul connection used by the consul allocator.
+type ConsulAllocatorConf struct {
+	Host   string `json:",optional"`     // consul hosts
+	Scheme string `json:",default=http"` // consul scheme
+	Token  string `json:",optional"`     // consul token
+}
+
+// CheckpointConf is the config of the persistent high-water timestamp.
+// Type is the checkpoint store. example: "file", "redis"
+// Path is the checkpoint file path, used by the file store. example: "/data/snake.checkpoint"
+// Key is the redis key prefix, the worker id is appended. example: "snake/checkpoint/order-api"
+// Interval is the refresh interval in milliseconds. example: 1000
+// MaxWait is how long NewSnake waits for wall time to pass the checkpoint, in milliseconds. example: 5000
+type CheckpointConf struct {
+	Type     string          `json:",optional,options=file|redis"`
+	Path     string          `json:",optional"`
+	Key      string          `json:",optional"`
+	Interval int64           `json:",default=1000"`
+	MaxWait  int64           `json:",default=5000"`
+	Redis    redis.RedisConf `json:",optional"`
 }
 
 func (c *Conf) Validate() error {
 
-	if c.Epoch <= 0 {
-		c.Epoch = defaultEpoch
+	switch c.Layout {
+	case "", LayoutSnowflake:
+		c.Layout = LayoutSnowflake
+		if c.Epoch <= 0 {
+			c.Epoch = defaultEpoch
+		}
+	case LayoutSonyflake:
+		if c.Epoch <= 0 {
+			c.Epoch = sonyflakeEpoch
+		}
+		c.TimeUnit = sonyflakeTimeUnit
+		c.SequenceBits = sonyflakeSequenceBits
+		c.WorkerIDBits = sonyflakeMachineIDBits
+		c.DatacenterBits = 0
+	default:
+		return errors.New("unknown layout: " + c.Layout)
 	}
 
 	if c.WorkerIDBits <= 0 || c.WorkerIDBits > 63 {
@@ -31,12 +112,95 @@ func (c *Conf) Validate() error {
 		c.SequenceBits = defaultSequenceBits
 	}
 
+	if c.TimeUnit <= 0 {
+		c.TimeUnit = defaultTimeUnit
+	}
+
 	if c.TimeDifference <= 0 {
 		c.TimeDifference = defaultTimeDifference
 	}
 
-	if int(c.WorkerIDBits)+int(c.SequenceBits) > 63 {
-		return errors.New("WorkerIDBits + SequenceBits must not exceed 63")
+	switch c.Clock {
+	case "":
+		c.Clock = ClockMonotonic
+	case ClockMonotonic, ClockWall:
+	default:
+		return errors.New("unknown clock: " + c.Clock)
+	}
+
+	if int(c.WorkerIDBits)+int(c.SequenceBits)+int(c.DatacenterBits) > 63 {
+		return errors.New("WorkerIDBits + SequenceBits + DatacenterBits must not exceed 63")
+	}
+
+	if err := c.Allocator.Validate(); err != nil {
+		return err
+	}
+
+	return c.Checkpoint.Validate()
+}
+
+// Validate validates c.
+func (c *AllocatorConf) Validate() error {
+	if len(c.Type) == 0 {
+		return nil
+	}
+
+	if len(c.Key) == 0 {
+		return errors.New("empty allocator key")
+	}
+	if c.TTL <= 0 {
+		c.TTL = defaultAllocatorTTL
+	}
+
+	switch c.Type {
+	case AllocatorTypeRedis:
+		if len(c.Redis.Type) == 0 {
+			c.Redis.Type = redis.NodeType
+		}
+		return c.Redis.Validate()
+	case AllocatorTypeConsul:
+		if len(c.Consul.Host) == 0 {
+			return errors.New("empty allocator consul hosts")
+		}
+		if len(c.Consul.Scheme) == 0 {
+			c.Consul.Scheme = defaultConsulScheme
+		}
+	default:
+		return errors.New("unknown allocator type: " + c.Type)
+	}
+
+	return nil
+}
+
+// Validate validates c.
+func (c *CheckpointConf) Validate() error {
+	// filled even without Type, a store set by WithCheckpointStore uses them too
+	if c.Interval <= 0 {
+		c.Interval = defaultCheckpointInterval
+	}
+	if c.MaxWait <= 0 {
+		c.MaxWait = defaultCheckpointMaxWait
+	}
+
+	if len(c.Type) == 0 {
+		return nil
+	}
+
+	switch c.Type {
+	case CheckpointTypeFile:
+		if len(c.Path) == 0 {
+			return errors.New("empty checkpoint path")
+		}
+	case CheckpointTypeRedis:
+		if len(c.Key) == 0 {
+			return errors.New("empty checkpoint key")
+		}
+		if len(c.Redis.Type) == 0 {
+			c.Redis.Type = redis.NodeType
+		}
+		return c.Redis.Validate()
+	default:
+		return errors.New("unknown checkpoint type: " + c.Type)
 	}
 
 	return nil
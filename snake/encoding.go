@@ -0,0 +1,215 @@
+package snake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	base62Alphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	base32Alphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUV" // base32hex, sorts like the ids it encodes
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+var (
+	// Base62Encoding encodes ids as 11 characters of [0-9A-Za-z].
+	Base62Encoding = NewEncoding(base62Alphabet)
+	// Base32Encoding encodes ids as 13 characters of the base32hex alphabet [0-9A-V].
+	Base32Encoding = NewEncoding(base32Alphabet)
+	// CrockfordEncoding encodes ids as 13 characters of Crockford's base32, decoding is
+	// case-insensitive, accepts I/L as 1 and O as 0, and ignores hyphens.
+	CrockfordEncoding = newCrockfordEncoding()
+)
+
+type (
+	// Encoding is a fixed-width string encoding of non-negative ids. Alphabets are in ASCII order,
+	// so encoded strings sort lexicographically in the same order as the ids.
+	Encoding struct {
+		alphabet  string
+		base      uint64
+		width     int
+		decodeMap [256]int16
+		normalize func(string) string
+	}
+
+	// Encoder generates and parses ids as strings on top of a Snake.
+	Encoder struct {
+		snake    Snake
+		encoding *Encoding
+	}
+
+	// ID is an id that marshals to JSON as a decimal string, since JavaScript numbers
+	// lose precision above 2^53.
+	ID int64
+)
+
+// NewEncoding returns an Encoding using alphabet, which must be in ascending ASCII order
+// to keep encoded strings sortable.
+func NewEncoding(alphabet string) *Encoding {
+	e := &Encoding{
+		alphabet: alphabet,
+		base:     uint64(len(alphabet)),
+	}
+	for i := range e.decodeMap {
+		e.decodeMap[i] = -1
+	}
+	for i := 0; i < len(alphabet); i++ {
+		e.decodeMap[alphabet[i]] = int16(i)
+	}
+
+	// number of digits needed for the largest int64
+	for n := uint64(1<<63 - 1); n > 0; n /= e.base {
+		e.width++
+	}
+
+	return e
+}
+
+func newCrockfordEncoding() *Encoding {
+	e := NewEncoding(crockfordAlphabet)
+	for i := 0; i < len(crockfordAlphabet); i++ {
+		e.decodeMap[unicode.ToLower(rune(crockfordAlphabet[i]))] = int16(i)
+	}
+	for _, alias := range []struct {
+		char  byte
+		value int16
+	}{{'I', 1}, {'i', 1}, {'L', 1}, {'l', 1}, {'O', 0}, {'o', 0}} {
+		e.decodeMap[alias.char] = alias.value
+	}
+	e.normalize = func(s string) string {
+		return strings.ReplaceAll(s, "-", "")
+	}
+
+	return e
+}
+
+// Width returns the length of every encoded id.
+func (e *Encoding) Width() int {
+	return e.width
+}
+
+// Encode encodes id, which must be non-negative, as a fixed-width string.
+func (e *Encoding) Encode(id int64) string {
+	buf := bytes.Repeat([]byte{e.alphabet[0]}, e.width)
+	for i, n := e.width-1, uint64(id); n > 0 && i >= 0; i-- {
+		buf[i] = e.alphabet[n%e.base]
+		n /= e.base
+	}
+
+	return string(buf)
+}
+
+// Decode decodes s back into an id.
+func (e *Encoding) Decode(s string) (int64, error) {
+	if e.normalize != nil {
+		s = e.normalize(s)
+	}
+	if len(s) != e.width {
+		return 0, fmt.Errorf("invalid encoded id %q: length must be %d", s, e.width)
+	}
+
+	var n uint64
+	for i := 0; i < len(s); i++ {
+		digit := e.decodeMap[s[i]]
+		if digit < 0 {
+			return 0, fmt.Errorf("invalid encoded id %q: illegal character %q", s, s[i])
+		}
+
+		hi, lo := bits.Mul64(n, e.base)
+		lo, carry := bits.Add64(lo, uint64(digit), 0)
+		if hi != 0 || carry != 0 || lo > 1<<63-1 {
+			return 0, fmt.Errorf("invalid encoded id %q: overflows int64", s)
+		}
+		n = lo
+	}
+
+	return int64(n), nil
+}
+
+// NewEncoder returns an Encoder generating ids from snake encoded with encoding.
+func NewEncoder(snake Snake, encoding *Encoding) *Encoder {
+	return &Encoder{snake: snake, encoding: encoding}
+}
+
+// Generate generates an id and returns it encoded.
+func (e *Encoder) Generate() (string, error) {
+	id, err := e.snake.Generator()
+	if err != nil {
+		return "", err
+	}
+
+	return e.encoding.Encode(id), nil
+}
+
+// GenerateBatch generates n ids and returns them encoded.
+func (e *Encoder) GenerateBatch(n int) ([]string, error) {
+	ids, err := e.snake.GenerateBatch(n)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := make([]string, len(ids))
+	for i, id := range ids {
+		encoded[i] = e.encoding.Encode(id)
+	}
+	return encoded, nil
+}
+
+// Decode decodes an encoded id.
+func (e *Encoder) Decode(s string) (int64, error) {
+	return e.encoding.Decode(s)
+}
+
+// ParseID decodes s and parses the timestamp, worker id and sequence out of it.
+func (e *Encoder) ParseID(s string) (timestamp int64, workerID int64, sequence int64, err error) {
+	id, err := e.encoding.Decode(s)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	timestamp, workerID, sequence = e.snake.ParseID(id)
+	return timestamp, workerID, sequence, nil
+}
+
+// Int64 returns id as int64.
+func (id ID) Int64() int64 {
+	return int64(id)
+}
+
+// String returns id in decimal.
+func (id ID) String() string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// MarshalJSON marshals id as a decimal string.
+func (id ID) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(id.String())), nil
+}
+
+// UnmarshalJSON accepts both a decimal string and a plain number.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+
+	n, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid id %s: %w", data, err)
+	}
+
+	*id = ID(n)
+	return nil
+}
@@ -0,0 +1,145 @@
+package snake
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodingWidth(t *testing.T) {
+	assert.Equal(t, 11, Base62Encoding.Width())
+	assert.Equal(t, 13, Base32Encoding.Width())
+	assert.Equal(t, 13, CrockfordEncoding.Width())
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	for _, enc := range []*Encoding{Base62Encoding, Base32Encoding, CrockfordEncoding} {
+		for _, id := range []int64{0, 1, 61, 62, 1 << 40, math.MaxInt64} {
+			s := enc.Encode(id)
+			assert.Len(t, s, enc.Width())
+
+			decoded, err := enc.Decode(s)
+			require.NoError(t, err)
+			assert.Equal(t, id, decoded)
+		}
+	}
+
+	assert.Equal(t, "00000000000", Base62Encoding.Encode(0))
+	assert.Equal(t, "AzL8n0Y58m7", Base62Encoding.Encode(math.MaxInt64))
+	assert.Equal(t, "7VVVVVVVVVVVV", Base32Encoding.Encode(math.MaxInt64))
+	assert.Equal(t, "7ZZZZZZZZZZZZ", CrockfordEncoding.Encode(math.MaxInt64))
+}
+
+func TestEncodingSortable(t *testing.T) {
+	s, err := NewSnake(Conf{WorkerID: 1})
+	require.NoError(t, err)
+	ids, err := s.GenerateBatch(5000)
+	require.NoError(t, err)
+	ids = append([]int64{1, 62, 63, 3843}, ids...)
+
+	for _, enc := range []*Encoding{Base62Encoding, Base32Encoding, CrockfordEncoding} {
+		encoded := make([]string, len(ids))
+		for i, id := range ids {
+			encoded[i] = enc.Encode(id)
+		}
+		assert.True(t, sort.StringsAreSorted(encoded), "encoded ids must sort like the ids")
+	}
+}
+
+func TestEncodingDecodeErrors(t *testing.T) {
+	_, err := Base62Encoding.Decode("abc")
+	assert.Error(t, err)
+
+	_, err = Base62Encoding.Decode("0000000000-")
+	assert.Error(t, err)
+
+	_, err = Base62Encoding.Decode("zzzzzzzzzzz")
+	assert.Error(t, err, "overflows int64")
+
+	_, err = Base32Encoding.Decode("0000000000000W")
+	assert.Error(t, err)
+	_, err = Base32Encoding.Decode("000000000000W")
+	assert.Error(t, err)
+}
+
+func TestCrockfordDecodeAliases(t *testing.T) {
+	id := int64(1234567890123)
+	s := CrockfordEncoding.Encode(id)
+
+	decoded, err := CrockfordEncoding.Decode(s[:4] + "-" + s[4:])
+	require.NoError(t, err)
+	assert.Equal(t, id, decoded)
+
+	lower := []byte(s)
+	for i, c := range lower {
+		if c >= 'A' && c <= 'Z' {
+			lower[i] = c + 'a' - 'A'
+		}
+	}
+	decoded, err = CrockfordEncoding.Decode(string(lower))
+	require.NoError(t, err)
+	assert.Equal(t, id, decoded)
+
+	one, err := CrockfordEncoding.Decode("000000000000I")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), one)
+	zero, err := CrockfordEncoding.Decode("OOOOOOOOOOOOo")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), zero)
+}
+
+func TestEncoder(t *testing.T) {
+	s, err := NewSnake(Conf{WorkerID: 9})
+	require.NoError(t, err)
+	enc := NewEncoder(s, Base62Encoding)
+
+	str, err := enc.Generate()
+	require.NoError(t, err)
+	assert.Len(t, str, 11)
+
+	id, err := enc.Decode(str)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), s.GetWorkerIDFromID(id))
+
+	timestamp, workerID, sequence, err := enc.ParseID(str)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), workerID)
+	assert.Equal(t, s.GetTimestampFromID(id), timestamp)
+	assert.Equal(t, s.GetSequenceFromID(id), sequence)
+
+	_, _, _, err = enc.ParseID("!")
+	assert.Error(t, err)
+
+	batch, err := enc.GenerateBatch(10)
+	require.NoError(t, err)
+	assert.Len(t, batch, 10)
+	assert.True(t, sort.StringsAreSorted(batch))
+
+	_, err = enc.GenerateBatch(0)
+	assert.Error(t, err)
+}
+
+func TestIDJSON(t *testing.T) {
+	type order struct {
+		ID       ID  `json:"id"`
+		ParentID *ID `json:"parentId,omitempty"`
+	}
+
+	data, err := json.Marshal(order{ID: ID(math.MaxInt64)})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"9223372036854775807"}`, string(data))
+
+	var o order
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"9223372036854775807","parentId":42}`), &o))
+	assert.Equal(t, int64(math.MaxInt64), o.ID.Int64())
+	require.NotNil(t, o.ParentID)
+	assert.Equal(t, "42", o.ParentID.String())
+
+	require.NoError(t, json.Unmarshal([]byte(`{"id":null}`), &o))
+	assert.Error(t, json.Unmarshal([]byte(`{"id":"abc"}`), &o))
+	assert.Error(t, json.Unmarshal([]byte(`{"id":"1.5"}`), &o))
+}
@@ -0,0 +1,141 @@
+package snake
+
+import (
+	"context"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/metric"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+const (
+	metricsNamespace    = "snake"
+	generatorSubsystem  = "generator"
+	instrumentationName = "github.com/lerity-yao/czt-contrib/snake"
+
+	rollbackResultWaited  = "waited"
+	rollbackResultRefused = "refused"
+	waitReasonSequence    = "sequence_exhausted"
+	waitReasonRollback    = "clock_rollback"
+)
+
+// ==================== Prometheus 指标 ====================
+
+var (
+	// 生成 ID 总数
+	metricGeneratedTotal = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: generatorSubsystem,
+		Name:      "ids_total",
+		Help:      "生成 ID 总数",
+		Labels:    []string{},
+	})
+
+	// 序列号耗尽等待次数
+	metricSequenceExhaustedTotal = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: generatorSubsystem,
+		Name:      "sequence_exhausted_total",
+		Help:      "序列号耗尽等待下一时间单位的次数",
+		Labels:    []string{},
+	})
+
+	// 时钟回拨次数 (result: waited/refused)
+	metricClockRollbackTotal = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: generatorSubsystem,
+		Name:      "clock_rollback_total",
+		Help:      "时钟回拨次数，waited 为等待后恢复，refused 为拒绝生成",
+		Labels:    []string{"result"},
+	})
+
+	// Generator 等待耗时 (reason: sequence_exhausted/clock_rollback)
+	metricWaitDuration = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: generatorSubsystem,
+		Name:      "wait_duration_ms",
+		Help:      "Generator 等待耗时(ms)",
+		Labels:    []string{"reason"},
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100, 1000},
+	})
+
+	// 当前 WorkerID
+	metricWorkerID = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: metricsNamespace,
+		Subsystem: generatorSubsystem,
+		Name:      "worker_id",
+		Help:      "当前实例解析得到的 WorkerID",
+		Labels:    []string{},
+	})
+)
+
+// ==================== OpenTelemetry 指标 ====================
+
+// otelInstruments mirrors the prometheus metrics on the global MeterProvider,
+// they are no-ops until a MeterProvider is installed.
+type otelInstruments struct {
+	generated         otelmetric.Int64Counter
+	sequenceExhausted otelmetric.Int64Counter
+	clockRollback     otelmetric.Int64Counter
+	waitDuration      otelmetric.Float64Histogram
+	workerID          otelmetric.Int64Gauge
+}
+
+var instruments = newOtelInstruments(otel.GetMeterProvider())
+
+func newOtelInstruments(provider otelmetric.MeterProvider) *otelInstruments {
+	meter := provider.Meter(instrumentationName)
+	// instrument creation only fails on invalid names, the returned instruments are usable no-ops then
+	generated, _ := meter.Int64Counter("snake.generator.ids",
+		otelmetric.WithDescription("Number of generated ids"))
+	sequenceExhausted, _ := meter.Int64Counter("snake.generator.sequence_exhausted",
+		otelmetric.WithDescription("Number of waits for the next time unit after the sequence ran out"))
+	clockRollback, _ := meter.Int64Counter("snake.generator.clock_rollback",
+		otelmetric.WithDescription("Number of clock rollbacks, by result"))
+	waitDuration, _ := meter.Float64Histogram("snake.generator.wait_duration",
+		otelmetric.WithDescription("Time spent waiting in Generator"), otelmetric.WithUnit("ms"))
+	workerID, _ := meter.Int64Gauge("snake.generator.worker_id",
+		otelmetric.WithDescription("Resolved worker id"))
+
+	return &otelInstruments{
+		generated:         generated,
+		sequenceExhausted: sequenceExhausted,
+		clockRollback:     clockRollback,
+		waitDuration:      waitDuration,
+		workerID:          workerID,
+	}
+}
+
+func recordGenerated(n int64) {
+	metricGeneratedTotal.Add(float64(n))
+	instruments.generated.Add(context.Background(), n)
+}
+
+func recordSequenceExhausted(wait time.Duration) {
+	metricSequenceExhaustedTotal.Inc()
+	recordWait(waitReasonSequence, wait)
+	instruments.sequenceExhausted.Add(context.Background(), 1)
+}
+
+func recordClockRollback(result string, wait time.Duration) {
+	metricClockRollbackTotal.Inc(result)
+	instruments.clockRollback.Add(context.Background(), 1,
+		otelmetric.WithAttributes(attribute.String("result", result)))
+	if wait > 0 {
+		recordWait(waitReasonRollback, wait)
+	}
+}
+
+func recordWait(reason string, wait time.Duration) {
+	ms := float64(wait) / float64(time.Millisecond)
+	metricWaitDuration.ObserveFloat(ms, reason)
+	instruments.waitDuration.Record(context.Background(), ms,
+		otelmetric.WithAttributes(attribute.String("reason", reason)))
+}
+
+func recordWorkerID(workerID int64) {
+	metricWorkerID.Set(float64(workerID))
+	instruments.workerID.Record(context.Background(), workerID)
+}
@@ -0,0 +1,133 @@
+package snake
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeromicro/go-zero/core/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// useManualReader routes the otel instruments to a manual reader for the duration of the test.
+func useManualReader(t *testing.T) *sdkmetric.ManualReader {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	old := instruments
+	instruments = newOtelInstruments(provider)
+	t.Cleanup(func() {
+		instruments = old
+	})
+	return reader
+}
+
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Metrics {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	metrics := make(map[string]metricdata.Metrics)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+func sumOf(t *testing.T, m metricdata.Metrics) int64 {
+	sum, ok := m.Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+
+	var total int64
+	for _, dp := range sum.DataPoints {
+		total += dp.Value
+	}
+	return total
+}
+
+func TestMetrics_Generated(t *testing.T) {
+	reader := useManualReader(t)
+
+	s, err := NewSnake(Conf{WorkerID: 42})
+	require.NoError(t, err)
+	_, err = s.Generator()
+	require.NoError(t, err)
+	_, err = s.GenerateBatch(99)
+	require.NoError(t, err)
+
+	metrics := collect(t, reader)
+	assert.Equal(t, int64(100), sumOf(t, metrics["snake.generator.ids"]))
+
+	gauge, ok := metrics["snake.generator.worker_id"].Data.(metricdata.Gauge[int64])
+	require.True(t, ok)
+	require.Len(t, gauge.DataPoints, 1)
+	assert.Equal(t, int64(42), gauge.DataPoints[0].Value)
+}
+
+func TestMetrics_SequenceExhausted(t *testing.T) {
+	reader := useManualReader(t)
+
+	s, err := NewSnake(Conf{WorkerID: 1, SequenceBits: 2})
+	require.NoError(t, err)
+	cs := s.(*CommonSnake)
+	atomic.StoreInt64(&cs.timestamp, time.Now().UnixMilli())
+	atomic.StoreInt64(&cs.sequence, cs.maxSequence)
+
+	_, err = s.Generator()
+	require.NoError(t, err)
+
+	metrics := collect(t, reader)
+	assert.Equal(t, int64(1), sumOf(t, metrics["snake.generator.sequence_exhausted"]))
+	_, ok := metrics["snake.generator.wait_duration"].Data.(metricdata.Histogram[float64])
+	assert.True(t, ok)
+}
+
+func TestMetrics_ClockRollback(t *testing.T) {
+	reader := useManualReader(t)
+
+	s, err := NewSnake(Conf{WorkerID: 1, TimeDifference: 1})
+	require.NoError(t, err)
+	atomic.StoreInt64(&s.(*CommonSnake).timestamp, time.Now().UnixMilli()+1000)
+
+	_, err = s.Generator()
+	require.Error(t, err)
+
+	metrics := collect(t, reader)
+	rollback, ok := metrics["snake.generator.clock_rollback"].Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, rollback.DataPoints, 1)
+	result, _ := rollback.DataPoints[0].Attributes.Value("result")
+	assert.Equal(t, rollbackResultRefused, result.AsString())
+}
+
+func TestMetrics_Prometheus(t *testing.T) {
+	prometheus.Enable()
+
+	s, err := NewSnake(Conf{WorkerID: 7})
+	require.NoError(t, err)
+	_, err = s.Generator()
+	require.NoError(t, err)
+
+	families, err := prom.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	found := make(map[string]float64)
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			switch {
+			case m.GetCounter() != nil:
+				found[mf.GetName()] = m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				found[mf.GetName()] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	assert.GreaterOrEqual(t, found["snake_generator_ids_total"], float64(1))
+	assert.Equal(t, float64(7), found["snake_generator_worker_id"])
+}
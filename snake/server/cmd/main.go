@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/lerity-yao/czt-contrib/snake/server"
+	"github.com/zeromicro/go-zero/core/conf"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+var configFile = flag.String("f", "etc/snake.yaml", "the config file")
+
+func main() {
+	flag.Parse()
+
+	var c server.Conf
+	conf.MustLoad(*configFile, &c)
+
+	s := server.MustNewServer(c)
+	defer s.Stop()
+
+	logx.Infof("starting snake server, rpc: %q, rest port: %d", c.Rpc.ListenOn, c.Rest.Port)
+	s.Start()
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/lerity-yao/czt-contrib/registercenter/consul"
+	"github.com/lerity-yao/czt-contrib/snake"
+	"github.com/zeromicro/go-zero/rest"
+	"github.com/zeromicro/go-zero/zrpc"
+)
+
+const defaultMaxBatch = 1000
+
+// Conf is the config of the id-issuing service.
+// Rpc is the zrpc server, enabled when ListenOn is set.
+// Rest is the http server, enabled when Port is set.
+// Snake is the generator config, usually with Conf.Allocator so the service owns the worker id.
+// Consul registers the service, the rpc address is used when both servers are enabled.
+// MaxBatch is the max count of one GenerateBatch call. example: 1000
+type Conf struct {
+	Rpc      zrpc.RpcServerConf `json:",optional"`
+	Rest     rest.RestConf      `json:",optional"`
+	Snake    snake.Conf
+	Consul   consul.Conf `json:",optional"`
+	MaxBatch int         `json:",default=1000"`
+}
+
+// Validate validates c.
+func (c *Conf) Validate() error {
+	if !c.rpcEnabled() && !c.restEnabled() {
+		return errors.New("neither rpc nor rest server is configured")
+	}
+	if c.MaxBatch <= 0 {
+		c.MaxBatch = defaultMaxBatch
+	}
+
+	return nil
+}
+
+func (c *Conf) rpcEnabled() bool {
+	return len(c.Rpc.ListenOn) > 0
+}
+
+func (c *Conf) restEnabled() bool {
+	return c.Rest.Port > 0
+}
+
+func (c *Conf) consulEnabled() bool {
+	return len(c.Consul.Host) > 0
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/lerity-yao/czt-contrib/snake"
+	"github.com/lerity-yao/czt-contrib/snake/server/pb"
+	"github.com/zeromicro/go-zero/rest"
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ids are written as strings, javascript clients lose precision above 2^53.
+type (
+	batchRequest struct {
+		Count int32 `form:"count"`
+	}
+
+	parseRequest struct {
+		Id int64 `path:"id"`
+	}
+
+	generateReply struct {
+		Id snake.ID `json:"id"`
+	}
+
+	batchReply struct {
+		Ids []snake.ID `json:"ids"`
+	}
+
+	parseReply struct {
+		Id           snake.ID `json:"id"`
+		Timestamp    int64    `json:"timestamp"`
+		DatacenterId int64    `json:"datacenterId"`
+		WorkerId     int64    `json:"workerId"`
+		Sequence     int64    `json:"sequence"`
+	}
+
+	errorReply struct {
+		Code    uint32 `json:"code"`
+		Message string `json:"message"`
+	}
+)
+
+func routes(s *snakeServer) []rest.Route {
+	return []rest.Route{
+		{
+			Method:  http.MethodGet,
+			Path:    "/snake/id",
+			Handler: generateHandler(s),
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/snake/ids",
+			Handler: generateBatchHandler(s),
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/snake/parse/:id",
+			Handler: parseHandler(s),
+		},
+	}
+}
+
+func generateHandler(s *snakeServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.Generate(r.Context(), &pb.GenerateRequest{})
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		httpx.OkJsonCtx(r.Context(), w, generateReply{Id: snake.ID(resp.Id)})
+	}
+}
+
+func generateBatchHandler(s *snakeServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req batchRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			writeError(w, r, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+
+		resp, err := s.GenerateBatch(r.Context(), &pb.GenerateBatchRequest{Count: req.Count})
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		ids := make([]snake.ID, len(resp.Ids))
+		for i, id := range resp.Ids {
+			ids[i] = snake.ID(id)
+		}
+		httpx.OkJsonCtx(r.Context(), w, batchReply{Ids: ids})
+	}
+}
+
+func parseHandler(s *snakeServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req parseRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			writeError(w, r, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+
+		resp, err := s.Parse(r.Context(), &pb.ParseRequest{Id: req.Id})
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		httpx.OkJsonCtx(r.Context(), w, parseReply{
+			Id:           snake.ID(resp.Id),
+			Timestamp:    resp.Timestamp,
+			DatacenterId: resp.DatacenterId,
+			WorkerId:     resp.WorkerId,
+			Sequence:     resp.Sequence,
+		})
+	}
+}
+
+// writeError writes err as json, the http status follows the grpc code of err.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+	code := http.StatusInternalServerError
+	switch st.Code() {
+	case codes.InvalidArgument:
+		code = http.StatusBadRequest
+	case codes.Unavailable:
+		code = http.StatusServiceUnavailable
+	}
+
+	httpx.WriteJsonCtx(r.Context(), w, code, errorReply{
+		Code:    uint32(st.Code()),
+		Message: st.Message(),
+	})
+}
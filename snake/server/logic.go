@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+
+	"github.com/lerity-yao/czt-contrib/snake"
+	"github.com/lerity-yao/czt-contrib/snake/server/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// snakeServer implements pb.SnakeServer on top of a Snake, the rest handlers share it.
+type snakeServer struct {
+	pb.UnimplementedSnakeServer
+	snake    snake.Snake
+	maxBatch int
+}
+
+func newSnakeServer(sk snake.Snake, maxBatch int) *snakeServer {
+	return &snakeServer{
+		snake:    sk,
+		maxBatch: maxBatch,
+	}
+}
+
+// Generate returns a new id.
+func (s *snakeServer) Generate(_ context.Context, _ *pb.GenerateRequest) (*pb.GenerateResponse, error) {
+	id, err := s.snake.Generator()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.GenerateResponse{Id: id}, nil
+}
+
+// GenerateBatch returns in.Count new ids, at most MaxBatch.
+func (s *snakeServer) GenerateBatch(_ context.Context, in *pb.GenerateBatchRequest) (*pb.GenerateBatchResponse, error) {
+	if in.Count <= 0 || int(in.Count) > s.maxBatch {
+		return nil, status.Errorf(codes.InvalidArgument, "count must be in [1, %d], got %d", s.maxBatch, in.Count)
+	}
+
+	ids, err := s.snake.GenerateBatch(int(in.Count))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.GenerateBatchResponse{Ids: ids}, nil
+}
+
+// Parse splits in.Id into its fields with the layout of the service.
+func (s *snakeServer) Parse(_ context.Context, in *pb.ParseRequest) (*pb.ParseResponse, error) {
+	if in.Id <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid id: %d", in.Id)
+	}
+
+	timestamp, workerID, sequence := s.snake.ParseID(in.Id)
+	return &pb.ParseResponse{
+		Id:           in.Id,
+		Timestamp:    timestamp,
+		DatacenterId: s.snake.GetDatacenterIDFromID(in.Id),
+		WorkerId:     workerID,
+		Sequence:     sequence,
+	}, nil
+}
+
+// toStatus maps a generator error to Unavailable, both a clock rollback and a lost lease are worth retrying
+// on another instance.
+func toStatus(err error) error {
+	return status.Error(codes.Unavailable, err.Error())
+}
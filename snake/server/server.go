@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/lerity-yao/czt-contrib/registercenter/consul"
+	"github.com/lerity-yao/czt-contrib/snake"
+	"github.com/lerity-yao/czt-contrib/snake/server/pb"
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/service"
+	"github.com/zeromicro/go-zero/rest"
+	"github.com/zeromicro/go-zero/zrpc"
+	"google.golang.org/grpc"
+)
+
+type (
+	// Server serves snake ids over zrpc and rest, so one instance can own the worker id for non-Go clients.
+	Server struct {
+		conf       Conf
+		snake      snake.Snake
+		ownSnake   bool
+		rpcServer  *zrpc.RpcServer
+		restServer *rest.Server
+		consul     consul.Client
+		group      *service.ServiceGroup
+	}
+
+	// Option customizes the server during creation.
+	Option func(s *Server)
+)
+
+// WithSnake serves ids from sk instead of creating one from Conf.Snake, the caller keeps ownership of sk.
+func WithSnake(sk snake.Snake) Option {
+	return func(s *Server) {
+		s.snake = sk
+	}
+}
+
+// MustNewServer creates a new Server.
+// It panics if the configuration is invalid.
+func MustNewServer(c Conf, opts ...Option) *Server {
+	s, err := NewServer(c, opts...)
+	if err != nil {
+		logx.Must(err)
+	}
+	return s
+}
+
+// NewServer creates a new Server.
+// The rpc server is created when Rpc.ListenOn is set, the rest server when Rest.Port is set,
+// and the service is registered to consul on Start when Consul.Host is set.
+func NewServer(c Conf, opts ...Option) (*Server, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		conf:  c,
+		group: service.NewServiceGroup(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.snake == nil {
+		sk, err := snake.NewSnake(c.Snake)
+		if err != nil {
+			return nil, err
+		}
+		s.snake = sk
+		s.ownSnake = true
+	}
+
+	if err := s.setUp(); err != nil {
+		s.closeSnake()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Server) setUp() error {
+	handler := newSnakeServer(s.snake, s.conf.MaxBatch)
+
+	if s.conf.rpcEnabled() {
+		rpcServer, err := zrpc.NewServer(s.conf.Rpc, func(grpcServer *grpc.Server) {
+			pb.RegisterSnakeServer(grpcServer, handler)
+		})
+		if err != nil {
+			return err
+		}
+		s.rpcServer = rpcServer
+		s.group.Add(rpcServer)
+	}
+
+	if s.conf.restEnabled() {
+		restServer, err := rest.NewServer(s.conf.Rest)
+		if err != nil {
+			return err
+		}
+		restServer.AddRoutes(routes(handler))
+		s.restServer = restServer
+		s.group.Add(restServer)
+	}
+
+	if s.conf.consulEnabled() {
+		client, err := consul.NewService(s.listenOn(), s.conf.Consul)
+		if err != nil {
+			return err
+		}
+		s.consul = client
+	}
+
+	return nil
+}
+
+// Start registers the service to consul if configured, then serves until Stop is called.
+func (s *Server) Start() {
+	if s.consul != nil {
+		logx.Must(s.consul.RegisterService())
+	}
+
+	s.group.Start()
+}
+
+// Stop deregisters the service, stops the servers and closes the snake created by NewServer.
+func (s *Server) Stop() {
+	if s.consul != nil {
+		if err := s.consul.DeregisterService(); err != nil {
+			logx.Errorf("deregister service %s error: %v", s.consul.GetServiceID(), err)
+		}
+	}
+
+	s.group.Stop()
+	s.closeSnake()
+}
+
+// listenOn returns the address registered to consul, the rpc address takes precedence.
+func (s *Server) listenOn() string {
+	if s.conf.rpcEnabled() {
+		return s.conf.Rpc.ListenOn
+	}
+
+	return fmt.Sprintf("%s:%d", s.conf.Rest.Host, s.conf.Rest.Port)
+}
+
+func (s *Server) closeSnake() {
+	if !s.ownSnake {
+		return
+	}
+
+	if err := s.snake.Close(); err != nil {
+		logx.Errorf("close snake error: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/lerity-yao/czt-contrib/snake"
+	"github.com/lerity-yao/czt-contrib/snake/server/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeromicro/go-zero/rest/router"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestSnake(t *testing.T) snake.Snake {
+	sk, err := snake.NewSnake(snake.Conf{
+		WorkerIDBits:   10,
+		SequenceBits:   12,
+		DatacenterBits: 2,
+		Epoch:          1704067200000,
+		TimeDifference: 5,
+		WorkerID:       7,
+		DatacenterID:   3,
+	})
+	require.NoError(t, err)
+	return sk
+}
+
+func newTestClient(t *testing.T, s *snakeServer) pb.SnakeClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterSnakeServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return pb.NewSnakeClient(conn)
+}
+
+func TestConfValidate(t *testing.T) {
+	var c Conf
+	assert.Error(t, c.Validate())
+
+	c.Rest.Port = 8080
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, defaultMaxBatch, c.MaxBatch)
+}
+
+func TestRpc(t *testing.T) {
+	client := newTestClient(t, newSnakeServer(newTestSnake(t), 100))
+	ctx := context.Background()
+
+	gen, err := client.Generate(ctx, &pb.GenerateRequest{})
+	require.NoError(t, err)
+	assert.Positive(t, gen.Id)
+
+	batch, err := client.GenerateBatch(ctx, &pb.GenerateBatchRequest{Count: 100})
+	require.NoError(t, err)
+	assert.Len(t, batch.Ids, 100)
+	for i := 1; i < len(batch.Ids); i++ {
+		assert.Greater(t, batch.Ids[i], batch.Ids[i-1])
+	}
+
+	parsed, err := client.Parse(ctx, &pb.ParseRequest{Id: gen.Id})
+	require.NoError(t, err)
+	assert.Equal(t, gen.Id, parsed.Id)
+	assert.Equal(t, int64(3), parsed.DatacenterId)
+	assert.Equal(t, int64(7), parsed.WorkerId)
+	assert.Positive(t, parsed.Timestamp)
+}
+
+func TestRpc_InvalidArgument(t *testing.T) {
+	client := newTestClient(t, newSnakeServer(newTestSnake(t), 100))
+	ctx := context.Background()
+
+	for _, count := range []int32{0, -1, 101} {
+		_, err := client.GenerateBatch(ctx, &pb.GenerateBatchRequest{Count: count})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "count %d", count)
+	}
+
+	_, err := client.Parse(ctx, &pb.ParseRequest{Id: 0})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestRpc_LeaseLost(t *testing.T) {
+	pool := snake.NewMemoryAllocatorPool()
+	allocator := pool.NewAllocator()
+	sk, err := snake.NewSnake(snake.Conf{
+		WorkerIDBits:   10,
+		SequenceBits:   12,
+		Epoch:          1704067200000,
+		TimeDifference: 5,
+	}, snake.WithWorkerIDAllocator(allocator))
+	require.NoError(t, err)
+	allocator.Expire()
+
+	client := newTestClient(t, newSnakeServer(sk, 100))
+	_, err = client.Generate(context.Background(), &pb.GenerateRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestRest(t *testing.T) {
+	sk := newTestSnake(t)
+	rt := router.NewRouter()
+	for _, route := range routes(newSnakeServer(sk, 100)) {
+		require.NoError(t, rt.Handle(route.Method, route.Path, route.Handler))
+	}
+
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := do("/snake/id")
+	require.Equal(t, http.StatusOK, w.Code)
+	var gen map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &gen))
+	id, err := strconv.ParseInt(gen["id"], 10, 64)
+	require.NoError(t, err)
+
+	w = do("/snake/ids?count=5")
+	require.Equal(t, http.StatusOK, w.Code)
+	var batch batchReply
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &batch))
+	assert.Len(t, batch.Ids, 5)
+
+	w = do("/snake/parse/" + strconv.FormatInt(id, 10))
+	require.Equal(t, http.StatusOK, w.Code)
+	var parsed parseReply
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &parsed))
+	assert.Equal(t, snake.ID(id), parsed.Id)
+	assert.Equal(t, int64(7), parsed.WorkerId)
+	assert.Equal(t, int64(3), parsed.DatacenterId)
+	assert.Equal(t, sk.GetTimestampFromID(id), parsed.Timestamp)
+
+	assert.Equal(t, http.StatusBadRequest, do("/snake/ids?count=1000").Code)
+	assert.Equal(t, http.StatusBadRequest, do("/snake/ids").Code)
+	assert.Equal(t, http.StatusBadRequest, do("/snake/parse/abc").Code)
+}
+
+func TestNewServer(t *testing.T) {
+	_, err := NewServer(Conf{})
+	assert.Error(t, err)
+
+	var c Conf
+	c.Rest.Name = "snake"
+	c.Rest.Host = "127.0.0.1"
+	c.Rest.Port = 18089
+	c.Rpc.Name = "snake"
+	c.Rpc.ListenOn = "127.0.0.1:18090"
+	s, err := NewServer(c, WithSnake(newTestSnake(t)))
+	require.NoError(t, err)
+	assert.NotNil(t, s.rpcServer)
+	assert.NotNil(t, s.restServer)
+	assert.Nil(t, s.consul)
+	assert.Equal(t, "127.0.0.1:18090", s.listenOn())
+}
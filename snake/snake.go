@@ -1,58 +1,133 @@
 package snake
 
 import (
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zeromicro/go-zero/core/netx"
+	"github.com/zeromicro/go-zero/core/proc"
 )
 
+// ErrSnakeClosed is returned by Generator once the snake is closed, its worker id may already be leased by another process.
+var ErrSnakeClosed = errors.New("snake closed, refusing to generate id")
+
 type (
 	Snake interface {
 		Generator() (int64, error)
+		GenerateBatch(n int) ([]int64, error)
+		Reserve(n int) (IDRange, error)
 		ParseID(id int64) (timestamp int64, workerID int64, sequence int64)
 		GetTimestampFromID(id int64) int64
 		GetWorkerIDFromID(id int64) int64
+		GetDatacenterIDFromID(id int64) int64
 		GetSequenceFromID(id int64) int64
 		GetTimeFromID(id int64) time.Time
+		Close() error
 	}
 
+	// Option customizes the snake during creation.
+	Option func(c *CommonSnake)
+
 	CommonSnake struct {
 		snakeConf          Conf
 		maxWorkerID        int64
 		maxSequence        int64
+		maxDatacenterID    int64
+		sequenceShift      uint8
 		workerIDShift      uint8
+		datacenterIDShift  uint8
 		timestampLeftShift uint8
 		workerID           int64
-		timestamp          int64 // last timestamp
-		sequence           int64 // sequence number
+		datacenterID       int64
+		mu                 sync.Mutex
+		closed             bool  // set by Close, guarded by mu
+		timestamp          int64 // last timestamp, guarded by mu, read atomically outside it
+		sequence           int64 // sequence number, guarded by mu
+		allocator          WorkerIDAllocator
+		leaseLost          <-chan struct{}
+		checkpoint         CheckpointStore
+		clock              Clock
+		stopCh             chan struct{}
+		closeOnce          sync.Once
 	}
 )
 
-func MustNewSnake(snakeConf Conf) Snake {
-	snake, err := NewSnake(snakeConf)
+// WithWorkerIDAllocator sets the allocator used to lease the worker id, it takes precedence over Conf.Allocator.
+func WithWorkerIDAllocator(allocator WorkerIDAllocator) Option {
+	return func(c *CommonSnake) {
+		c.allocator = allocator
+	}
+}
+
+// WithClock sets the time source, it takes precedence over Conf.Clock.
+func WithClock(clock Clock) Option {
+	return func(c *CommonSnake) {
+		c.clock = clock
+	}
+}
+
+// WithCheckpointStore sets the store persisting the high-water timestamp, it takes precedence over Conf.Checkpoint.
+func WithCheckpointStore(store CheckpointStore) Option {
+	return func(c *CommonSnake) {
+		c.checkpoint = store
+	}
+}
+
+func MustNewSnake(snakeConf Conf, opts ...Option) Snake {
+	snake, err := NewSnake(snakeConf, opts...)
 	logx.Must(err)
 	return snake
 }
 
-func NewSnake(conf Conf) (Snake, error) {
+func NewSnake(conf Conf, opts ...Option) (Snake, error) {
 	if err := conf.Validate(); err != nil {
 		return nil, err
 	}
 
-	c := &CommonSnake{snakeConf: conf}
+	c := &CommonSnake{snakeConf: conf, stopCh: make(chan struct{})}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.clock == nil {
+		c.clock = newClock(conf.Clock)
+	}
+
 	c.CalculateMaxWorkerID()
 	c.CalculateMaxSequence()
+	c.CalculateMaxDatacenterID()
+	c.CalculateSequenceShift()
 	c.CalculateWorkerIDShift()
+	c.CalculateDatacenterIDShift()
 	c.CalculateTimestampLeftShift()
+	if err := c.CalculateDatacenterID(); err != nil {
+		return nil, err
+	}
 	if err := c.CalculateWorkerID(); err != nil {
 		return nil, err
 	}
+	recordWorkerID(c.workerID)
+
+	if err := c.initCheckpoint(); err != nil {
+		// keep the persisted checkpoint untouched, only give the worker id back
+		c.checkpoint = nil
+		_ = c.Close()
+		return nil, err
+	}
+
+	if c.allocator != nil || c.checkpoint != nil {
+		proc.AddShutdownListener(func() {
+			if err := c.Close(); err != nil {
+				logx.Errorf("snake close failed, worker id: %d, error: %v", c.workerID, err)
+			}
+		})
+	}
 
 	return c, nil
 }
@@ -73,12 +148,42 @@ func (c *CommonSnake) CalculateMaxSequence() {
 	}
 }
 
+func (c *CommonSnake) CalculateMaxDatacenterID() {
+	c.maxDatacenterID = -1 ^ (-1 << c.snakeConf.DatacenterBits)
+}
+
+// CalculateSequenceShift places the sequence below the worker id in the sonyflake layout.
+func (c *CommonSnake) CalculateSequenceShift() {
+	if c.snakeConf.Layout == LayoutSonyflake {
+		c.sequenceShift = c.snakeConf.WorkerIDBits
+	} else {
+		c.sequenceShift = 0
+	}
+}
+
 func (c *CommonSnake) CalculateWorkerIDShift() {
-	c.workerIDShift = c.snakeConf.SequenceBits
+	if c.snakeConf.Layout == LayoutSonyflake {
+		c.workerIDShift = 0
+	} else {
+		c.workerIDShift = c.snakeConf.SequenceBits
+	}
+}
+
+func (c *CommonSnake) CalculateDatacenterIDShift() {
+	c.datacenterIDShift = c.snakeConf.SequenceBits + c.snakeConf.WorkerIDBits
 }
 
 func (c *CommonSnake) CalculateTimestampLeftShift() {
-	c.timestampLeftShift = c.snakeConf.SequenceBits + c.snakeConf.WorkerIDBits
+	c.timestampLeftShift = c.snakeConf.SequenceBits + c.snakeConf.WorkerIDBits + c.snakeConf.DatacenterBits
+}
+
+func (c *CommonSnake) CalculateDatacenterID() error {
+	if c.snakeConf.DatacenterID < 0 || c.snakeConf.DatacenterID > c.maxDatacenterID {
+		return fmt.Errorf("DatacenterID %d is out of range [0, %d]", c.snakeConf.DatacenterID, c.maxDatacenterID)
+	}
+
+	c.datacenterID = c.snakeConf.DatacenterID
+	return nil
 }
 
 func (c *CommonSnake) CalculateWorkerID() error {
@@ -90,6 +195,27 @@ func (c *CommonSnake) CalculateWorkerID() error {
 		return nil
 	}
 
+	if c.allocator == nil {
+		allocator, err := newAllocator(c.snakeConf.Allocator)
+		if err != nil {
+			return err
+		}
+		c.allocator = allocator
+	}
+	if c.allocator != nil {
+		workerID, err := c.allocator.Acquire(c.maxWorkerID)
+		if err != nil {
+			return err
+		}
+		if workerID < 0 || workerID > c.maxWorkerID {
+			_ = c.allocator.Release()
+			return fmt.Errorf("WorkerID %d is out of range [0, %d]", workerID, c.maxWorkerID)
+		}
+		c.workerID = workerID
+		c.leaseLost = c.allocator.Lost()
+		return nil
+	}
+
 	ip := os.Getenv(envPodIP)
 	if len(ip) == 0 {
 		ip = netx.InternalIp()
@@ -109,70 +235,112 @@ func (c *CommonSnake) CalculateWorkerID() error {
 }
 
 func (c *CommonSnake) Generator() (int64, error) {
+	timestamp, sequence, _, err := c.nextSequence(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.composeID(timestamp, sequence), nil
+}
 
-	currentTime := time.Now().UnixMilli()
+// nextSequence claims up to n consecutive sequence numbers of one millisecond in a single step,
+// returning the millisecond, the first claimed sequence and how many were claimed.
+// The timestamp and the sequence are guarded together by mu, so no caller can pair a new
+// millisecond with the sequence of the previous one.
+func (c *CommonSnake) nextSequence(n int64) (timestamp int64, sequence int64, count int64, err error) {
+	if c.leaseLost != nil {
+		select {
+		case <-c.leaseLost:
+			return 0, 0, 0, ErrWorkerIDLeaseLost
+		default:
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, 0, 0, ErrSnakeClosed
+	}
+
+	currentTime := c.currentTime()
 
 	lastTimestamp := atomic.LoadInt64(&c.timestamp)
-	var newTimestamp, newSequence int64
 
 	for {
 		if currentTime < lastTimestamp {
 			timeDifference := lastTimestamp - currentTime
 			if timeDifference <= c.snakeConf.TimeDifference {
 
-				waitUntil := time.Now().Add(time.Duration(c.snakeConf.TimeDifference) * time.Millisecond)
-				for time.Now().Before(waitUntil) && currentTime < lastTimestamp {
-					time.Sleep(100 * time.Microsecond) //
-					currentTime = time.Now().UnixMilli()
-				}
+				waitStart := c.clock.Now()
+				currentTime = c.sleepUntil(lastTimestamp)
 
 				if currentTime < lastTimestamp {
-					return 0, fmt.Errorf("clock moved backwards, refusing to generate id for %d milliseconds", timeDifference)
+					recordClockRollback(rollbackResultRefused, c.clock.Now().Sub(waitStart))
+					return 0, 0, 0, fmt.Errorf("clock moved backwards, refusing to generate id for %d milliseconds", timeDifference)
 				}
+				recordClockRollback(rollbackResultWaited, c.clock.Now().Sub(waitStart))
 			} else {
-				return 0, fmt.Errorf("clock moved backwards, refusing to generate id for %d milliseconds", timeDifference)
+				recordClockRollback(rollbackResultRefused, 0)
+				return 0, 0, 0, fmt.Errorf("clock moved backwards, refusing to generate id for %d milliseconds", timeDifference)
 			}
 		}
 
 		if currentTime == lastTimestamp {
-			currentSequence := atomic.LoadInt64(&c.sequence)
-
-			if currentSequence >= c.maxSequence {
-				for currentTime <= lastTimestamp {
-					time.Sleep(100 * time.Microsecond)
-					currentTime = time.Now().UnixMilli()
-				}
+			if c.sequence >= c.maxSequence {
+				waitStart := c.clock.Now()
+				currentTime = c.sleepUntil(lastTimestamp + c.snakeConf.TimeUnit)
+				recordSequenceExhausted(c.clock.Now().Sub(waitStart))
 				continue
 			}
 
-			newSequence = currentSequence + 1
-			if atomic.CompareAndSwapInt64(&c.sequence, currentSequence, newSequence) {
-				newTimestamp = currentTime
-				break
-			}
-		} else {
-			if atomic.CompareAndSwapInt64(&c.timestamp, lastTimestamp, currentTime) {
-				atomic.StoreInt64(&c.sequence, 0)
-				newTimestamp = currentTime
-				newSequence = 0
-				break
-			}
+			count = min(n, c.maxSequence-c.sequence)
+			sequence = c.sequence + 1
+			c.sequence += count
+			recordGenerated(count)
+			return currentTime, sequence, count, nil
 		}
 
-		lastTimestamp = atomic.LoadInt64(&c.timestamp)
+		count = n
+		if count-1 > c.maxSequence {
+			count = c.maxSequence + 1
+		}
+		atomic.StoreInt64(&c.timestamp, currentTime)
+		c.sequence = count - 1
+		recordGenerated(count)
+		return currentTime, 0, count, nil
 	}
+}
 
-	snowflake := ((newTimestamp - c.snakeConf.Epoch) << c.timestampLeftShift) |
+func (c *CommonSnake) composeID(timestamp int64, sequence int64) int64 {
+	return ((timestamp - c.snakeConf.Epoch) / c.snakeConf.TimeUnit << c.timestampLeftShift) |
+		(c.datacenterID << c.datacenterIDShift) |
 		(c.workerID << c.workerIDShift) |
-		newSequence
+		(sequence << c.sequenceShift)
+}
+
+// currentTime returns the current time in milliseconds, truncated to the TimeUnit boundary since Epoch.
+func (c *CommonSnake) currentTime() int64 {
+	return c.truncateTime(c.clock.Now().UnixMilli())
+}
+
+// sleepUntil sleeps until the clock reaches millis, then returns the current time like currentTime.
+func (c *CommonSnake) sleepUntil(millis int64) int64 {
+	if d := time.UnixMilli(millis).Sub(c.clock.Now()); d > 0 {
+		c.clock.Sleep(d)
+	}
 
-	return snowflake, nil
+	return c.currentTime()
+}
+
+func (c *CommonSnake) truncateTime(millis int64) int64 {
+	return millis - (millis-c.snakeConf.Epoch)%c.snakeConf.TimeUnit
 }
 
 func (c *CommonSnake) ParseID(id int64) (timestamp int64, workerID int64, sequence int64) {
-	sequence = id & c.maxSequence
+	sequence = (id >> c.sequenceShift) & c.maxSequence
 	workerID = (id >> c.workerIDShift) & c.maxWorkerID
-	timestamp = (id >> c.timestampLeftShift) + c.snakeConf.Epoch
+	timestamp = (id>>c.timestampLeftShift)*c.snakeConf.TimeUnit + c.snakeConf.Epoch
 
 	return timestamp, workerID, sequence
 }
@@ -187,6 +355,10 @@ func (c *CommonSnake) GetWorkerIDFromID(id int64) int64 {
 	return workerID
 }
 
+func (c *CommonSnake) GetDatacenterIDFromID(id int64) int64 {
+	return (id >> c.datacenterIDShift) & c.maxDatacenterID
+}
+
 func (c *CommonSnake) GetSequenceFromID(id int64) int64 {
 	_, _, sequence := c.ParseID(id)
 	return sequence
@@ -196,3 +368,52 @@ func (c *CommonSnake) GetTimeFromID(id int64) time.Time {
 	timestamp, _, _ := c.ParseID(id)
 	return time.UnixMilli(timestamp)
 }
+
+func (c *CommonSnake) initCheckpoint() error {
+	if c.checkpoint == nil {
+		store, err := newCheckpointStore(c.snakeConf.Checkpoint, c.workerID)
+		if err != nil {
+			return err
+		}
+		c.checkpoint = store
+	}
+	if c.checkpoint == nil {
+		return nil
+	}
+
+	if c.snakeConf.Checkpoint.Interval <= 0 {
+		c.snakeConf.Checkpoint.Interval = defaultCheckpointInterval
+	}
+	if c.snakeConf.Checkpoint.MaxWait <= 0 {
+		c.snakeConf.Checkpoint.MaxWait = defaultCheckpointMaxWait
+	}
+
+	return c.restoreCheckpoint()
+}
+
+// Close persists the final checkpoint and releases the leased worker id, if any.
+func (c *CommonSnake) Close() error {
+	var errs []error
+	c.closeOnce.Do(func() {
+		// waits for the in-flight claims, no id is minted once closed is set,
+		// so the checkpoint is final and the worker id can be given away
+		c.mu.Lock()
+		c.closed = true
+		timestamp := atomic.LoadInt64(&c.timestamp)
+		c.mu.Unlock()
+
+		close(c.stopCh)
+		if c.checkpoint != nil {
+			if err := c.checkpoint.Save(timestamp); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if c.allocator != nil {
+			if err := c.allocator.Release(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	})
+
+	return errors.Join(errs...)
+}
@@ -3,7 +3,6 @@ package snake
 import (
 	"fmt"
 	"sync"
-	"sync/atomic"
 	"testing"
 	"time"
 
@@ -325,28 +324,45 @@ func TestNewSnake_BitsOverflow(t *testing.T) {
 	}
 }
 
+// stalledClock never moves on Sleep, like a wall clock stepped back again while Generator waits.
+type stalledClock struct {
+	*FakeClock
+}
+
+func (stalledClock) Sleep(time.Duration) {}
+
+func newFakeClockSnake(t *testing.T, conf Conf, clock Clock) *CommonSnake {
+	snk, err := NewSnake(conf, WithClock(clock))
+	assert.NoError(t, err)
+	return snk.(*CommonSnake)
+}
+
 // TestGenerator_ClockBackwardsSmall covers the small-difference wait-and-retry path.
-// We manipulate the internal timestamp to be slightly in the future.
 func TestGenerator_ClockBackwardsSmall(t *testing.T) {
 	conf := Conf{
 		WorkerIDBits:   10,
 		SequenceBits:   12,
 		Epoch:          1704067200000,
-		TimeDifference: 50, // 50ms tolerance — large enough for the wait loop
+		TimeDifference: 5,
 		WorkerID:       1,
 	}
-	snk, err := NewSnake(conf)
+	start := time.UnixMilli(1735689600000)
+	clock := NewFakeClock(start)
+	snk := newFakeClockSnake(t, conf, clock)
+
+	id1, err := snk.Generator()
 	assert.NoError(t, err)
-	cs := snk.(*CommonSnake)
 
-	// Set the stored timestamp to 2ms in the future (within TimeDifference)
-	future := time.Now().UnixMilli() + 2
-	atomic.StoreInt64(&cs.timestamp, future)
+	// roll the clock back 2ms, within TimeDifference
+	clock.Add(-2 * time.Millisecond)
 
-	// Generator should wait and then succeed
-	id, err := snk.Generator()
+	// Generator should sleep exactly until the last timestamp and then succeed
+	id2, err := snk.Generator()
 	assert.NoError(t, err)
-	assert.True(t, id > 0)
+	assert.Greater(t, id2, id1)
+	assert.Equal(t, start, clock.Now())
+	assert.Equal(t, start.UnixMilli(), snk.GetTimestampFromID(id2))
+	assert.Equal(t, int64(1), snk.GetSequenceFromID(id2))
 }
 
 // TestGenerator_ClockBackwardsLarge covers the large-difference immediate-error path.
@@ -358,52 +374,50 @@ func TestGenerator_ClockBackwardsLarge(t *testing.T) {
 		TimeDifference: 1, // only 1ms tolerance
 		WorkerID:       1,
 	}
-	snk, err := NewSnake(conf)
+	clock := NewFakeClock(time.UnixMilli(1735689600000))
+	snk := newFakeClockSnake(t, conf, clock)
+
+	_, err := snk.Generator()
 	assert.NoError(t, err)
-	cs := snk.(*CommonSnake)
 
-	// Set the stored timestamp to far in the future (exceeds TimeDifference)
-	future := time.Now().UnixMilli() + 1000
-	atomic.StoreInt64(&cs.timestamp, future)
+	// roll the clock back far beyond TimeDifference
+	clock.Add(-time.Second)
+	rolledBack := clock.Now()
 
 	_, err = snk.Generator()
-	if err == nil {
-		t.Fatal("expected clock-backwards error")
-	}
+	assert.ErrorContains(t, err, "clock moved backwards, refusing to generate id for 1000 milliseconds")
+	assert.Equal(t, rolledBack, clock.Now(), "must not wait")
+
+	// once the clock catches up ids are issued again
+	clock.Add(time.Second + time.Millisecond)
+	_, err = snk.Generator()
+	assert.NoError(t, err)
 }
 
-// TestGenerator_ClockBackwardsSmallStillBehind covers line 129-131:
-// small difference (within TimeDifference), wait loop expires, still behind → error.
+// TestGenerator_ClockBackwardsSmallStillBehind covers the small-difference path where
+// the clock is still behind after waiting.
 func TestGenerator_ClockBackwardsSmallStillBehind(t *testing.T) {
 	conf := Conf{
 		WorkerIDBits:   10,
 		SequenceBits:   12,
 		Epoch:          1704067200000,
-		TimeDifference: 1, // 1ms wait window
+		TimeDifference: 5,
 		WorkerID:       1,
 	}
-	snk, err := NewSnake(conf)
+	clock := stalledClock{NewFakeClock(time.UnixMilli(1735689600000))}
+	snk := newFakeClockSnake(t, conf, clock)
+
+	_, err := snk.Generator()
 	assert.NoError(t, err)
-	cs := snk.(*CommonSnake)
 
-	// Set timestamp 200ms ahead — within TimeDifference=1 is false: 200 > 1, hits else-branch.
-	// Use a value just at the boundary: timeDifference == TimeDifference triggers the wait path,
-	// but then still can't catch up because the offset is large enough.
-	// timeDifference = lastTimestamp - currentTime; we need it <= TimeDifference(1) yet still ahead.
-	// Set it to exactly 1ms ahead: after waiting 1ms, real clock may still be behind.
-	// To guarantee this deterministically, we reset cs.timestamp inside a tight loop before calling.
-	now := time.Now().UnixMilli()
-	atomic.StoreInt64(&cs.timestamp, now+1) // exactly 1ms ahead = equals TimeDifference
-	// Immediately call Generator — difference is 1 which equals TimeDifference,
-	// so it enters the wait branch. After waiting 1ms window expires.
-	// On most machines currentTime will have advanced past lastTimestamp → success.
-	// But if it doesn't, we get the error. Either outcome is acceptable — we just
-	// need the branch to execute.
-	_, _ = snk.Generator() // may succeed or return error; either covers the branch
+	clock.Add(-3 * time.Millisecond)
+
+	_, err = snk.Generator()
+	assert.ErrorContains(t, err, "clock moved backwards, refusing to generate id for 3 milliseconds")
 }
 
-// TestGenerator_SameMillisecond_CASRetry exercises the CAS retry path in Generator.
-// We saturate the sequence at current ms so the loop retries.
+// TestGenerator_SameMillisecond_SequenceRollover exhausts the sequence of one millisecond,
+// so Generator must wait for the next one.
 func TestGenerator_SameMillisecond_SequenceRollover(t *testing.T) {
 	conf := Conf{
 		WorkerIDBits:   10,
@@ -412,24 +426,27 @@ func TestGenerator_SameMillisecond_SequenceRollover(t *testing.T) {
 		TimeDifference: 5,
 		WorkerID:       1,
 	}
-	snk, err := NewSnake(conf)
-	assert.NoError(t, err)
-	cs := snk.(*CommonSnake)
+	start := time.UnixMilli(1735689600000)
+	clock := NewFakeClock(start)
+	snk := newFakeClockSnake(t, conf, clock)
 
-	// Fix timestamp to now so all calls land in the same millisecond
-	now := time.Now().UnixMilli()
-	atomic.StoreInt64(&cs.timestamp, now)
-	atomic.StoreInt64(&cs.sequence, cs.maxSequence-1) // one before max
-
-	// This call bumps sequence to max (CAS path, same ms)
-	id1, err := snk.Generator()
-	assert.NoError(t, err)
-	assert.True(t, id1 > 0)
+	var last int64
+	for i := 0; i <= int(snk.maxSequence); i++ {
+		id, err := snk.Generator()
+		assert.NoError(t, err)
+		assert.Greater(t, id, last)
+		assert.Equal(t, start.UnixMilli(), snk.GetTimestampFromID(id))
+		last = id
+	}
+	assert.Equal(t, start, clock.Now())
 
-	// Next call: sequence is at max, Generator must wait for next ms
-	id2, err := snk.Generator()
+	// sequence is at max, Generator must sleep until the next millisecond
+	id, err := snk.Generator()
 	assert.NoError(t, err)
-	assert.True(t, id2 > id1)
+	assert.Greater(t, id, last)
+	assert.Equal(t, start.Add(time.Millisecond), clock.Now())
+	assert.Equal(t, start.UnixMilli()+1, snk.GetTimestampFromID(id))
+	assert.Equal(t, int64(0), snk.GetSequenceFromID(id))
 }
 
 // TestPodIPWorkerID verifies that setting POD_IP env var is used for worker ID derivation.
@@ -480,3 +497,81 @@ func TestSnakeCheckDuplicateIDs(t *testing.T) {
 	// 验证生成的ID数量是否正确
 	assert.Equal(t, numIDs, len(idSet), "Number of unique IDs should match generated count")
 }
+
+func TestSnakeDatacenterLayout(t *testing.T) {
+	conf := Conf{
+		WorkerIDBits:   5,
+		SequenceBits:   12,
+		DatacenterBits: 5,
+		WorkerID:       7,
+		DatacenterID:   3,
+	}
+	snk, err := NewSnake(conf)
+	assert.NoError(t, err)
+
+	id, err := snk.Generator()
+	assert.NoError(t, err)
+
+	timestamp, workerID, _ := snk.ParseID(id)
+	assert.Equal(t, int64(7), workerID)
+	assert.Equal(t, int64(3), snk.GetDatacenterIDFromID(id))
+	assert.InDelta(t, time.Now().UnixMilli(), timestamp, 1000)
+	assert.Equal(t, int64(3), id>>17&0x1F, "datacenter id sits above the worker id")
+}
+
+func TestSnakeDatacenterIDOutOfRange(t *testing.T) {
+	_, err := NewSnake(Conf{WorkerID: 1, DatacenterBits: 2, DatacenterID: 4})
+	assert.Error(t, err)
+
+	_, err = NewSnake(Conf{WorkerID: 1, DatacenterID: 1})
+	assert.Error(t, err, "datacenter id needs datacenter bits")
+}
+
+func TestSnakeBitsOverflowWithDatacenter(t *testing.T) {
+	_, err := NewSnake(Conf{WorkerIDBits: 30, SequenceBits: 30, DatacenterBits: 4, WorkerID: 1})
+	assert.Error(t, err)
+}
+
+func TestSnakeSecondsTimeUnit(t *testing.T) {
+	// 123456 whole seconds after the epoch, plus a fraction that must be truncated
+	clock := NewFakeClock(time.UnixMilli(defaultEpoch + 123456*1000 + 789))
+	conf := Conf{WorkerID: 1, TimeUnit: 1000}
+	snk, err := NewSnake(conf, WithClock(clock))
+	assert.NoError(t, err)
+
+	ids, err := snk.GenerateBatch(100)
+	assert.NoError(t, err)
+	for _, id := range ids {
+		assert.Equal(t, defaultEpoch+123456*1000, snk.GetTimestampFromID(id), "timestamp is truncated to seconds")
+		// one second per tick leaves 41 bits for roughly 69 thousand years
+		assert.Equal(t, int64(123456), id>>22, "tick counts seconds since epoch")
+	}
+}
+
+func TestSnakeSonyflakeLayout(t *testing.T) {
+	snk, err := NewSnake(Conf{Layout: LayoutSonyflake, WorkerID: 0x1234})
+	assert.NoError(t, err)
+	cs := snk.(*CommonSnake)
+	assert.Equal(t, sonyflakeEpoch, cs.snakeConf.Epoch)
+	assert.Equal(t, int64(1<<16-1), cs.maxWorkerID)
+	assert.Equal(t, int64(1<<8-1), cs.maxSequence)
+
+	// id minted by sonyflake: elapsed 10ms units | sequence | machine id
+	elapsed := int64(54321)
+	sonyflakeID := elapsed<<24 | 7<<16 | 0xABCD
+	timestamp, machineID, sequence := snk.ParseID(sonyflakeID)
+	assert.Equal(t, sonyflakeEpoch+elapsed*10, timestamp)
+	assert.Equal(t, int64(0xABCD), machineID)
+	assert.Equal(t, int64(7), sequence)
+	assert.Equal(t, time.UnixMilli(sonyflakeEpoch+elapsed*10), snk.GetTimeFromID(sonyflakeID))
+
+	id, err := snk.Generator()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0x1234), id&0xFFFF)
+	assert.InDelta(t, time.Now().UnixMilli(), snk.GetTimestampFromID(id), 1000)
+}
+
+func TestSnakeUnknownLayout(t *testing.T) {
+	_, err := NewSnake(Conf{Layout: "uuid", WorkerID: 1})
+	assert.Error(t, err)
+}